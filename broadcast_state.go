@@ -0,0 +1,164 @@
+package goperiscope
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type BroadcastState string
+
+const (
+	BroadcastStateNotStarted BroadcastState = "not_started"
+	BroadcastStateRunning    BroadcastState = "running"
+	BroadcastStateEnded      BroadcastState = "ended"
+	BroadcastStateTimedOut   BroadcastState = "timed_out"
+)
+
+// broadcastTransitions lists the states each known state can move to.
+var broadcastTransitions = map[BroadcastState][]BroadcastState{
+	BroadcastStateNotStarted: {BroadcastStateRunning, BroadcastStateTimedOut},
+	BroadcastStateRunning:    {BroadcastStateEnded, BroadcastStateTimedOut},
+	BroadcastStateEnded:      {},
+	BroadcastStateTimedOut:   {},
+}
+
+var ErrInvalidTransition = errors.New("invalid broadcast state transition")
+
+// UnmarshalJSON keeps states unknown to this package as they are, and treats null as the empty state.
+func (s *BroadcastState) UnmarshalJSON(b []byte) error {
+	var v *string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v == nil {
+		*s = ""
+		return nil
+	}
+	*s = BroadcastState(*v)
+	return nil
+}
+
+func (s BroadcastState) IsKnown() bool {
+	_, ok := broadcastTransitions[s]
+	return ok
+}
+
+func (s BroadcastState) IsLive() bool {
+	return s == BroadcastStateRunning
+}
+
+func (s BroadcastState) IsEnded() bool {
+	return s == BroadcastStateEnded || s == BroadcastStateTimedOut
+}
+
+// CanTransitionTo reports whether next may follow s. Unknown states are not restricted.
+func (s BroadcastState) CanTransitionTo(next BroadcastState) bool {
+	allowed, ok := broadcastTransitions[s]
+	if !ok {
+		return true
+	}
+	for _, a := range allowed {
+		if a == next {
+			return true
+		}
+	}
+	return false
+}
+
+type TransitionError struct {
+	BroadcastID string
+	From        BroadcastState
+	To          BroadcastState
+}
+
+func (e TransitionError) Error() string {
+	return fmt.Sprintf("broadcast %s cannot move from %s to %s", e.BroadcastID, e.From, e.To)
+}
+
+func (e TransitionError) Is(target error) bool {
+	if target == ErrInvalidTransition {
+		return true
+	}
+	return target == ErrBroadcastAlreadyStopped && e.From.IsEnded() && e.To == BroadcastStateEnded
+}
+
+// maxBroadcastStates bounds how many broadcasts broadcastStates remembers.
+const maxBroadcastStates = 1000
+
+// broadcastStates remembers the last state the API reported for each broadcast,
+// so that ClientImpl can reject illegal calls without a round trip.
+// The least recently used broadcasts are forgotten once there are more than limit of them.
+type broadcastStates struct {
+	mu     sync.Mutex
+	limit  int
+	states map[string]*list.Element
+	lru    *list.List
+}
+
+type broadcastStateEntry struct {
+	broadcastID string
+	state       BroadcastState
+}
+
+func newBroadcastStates() *broadcastStates {
+	return &broadcastStates{
+		limit:  maxBroadcastStates,
+		states: map[string]*list.Element{},
+		lru:    list.New(),
+	}
+}
+
+func (s *broadcastStates) set(broadcastID string, state BroadcastState) {
+	if s == nil || broadcastID == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.states[broadcastID]; ok {
+		e.Value.(*broadcastStateEntry).state = state
+		s.lru.MoveToFront(e)
+		return
+	}
+	s.states[broadcastID] = s.lru.PushFront(&broadcastStateEntry{broadcastID: broadcastID, state: state})
+	for s.lru.Len() > s.limit {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.states, oldest.Value.(*broadcastStateEntry).broadcastID)
+	}
+}
+
+func (s *broadcastStates) remove(broadcastID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.states[broadcastID]; ok {
+		s.lru.Remove(e)
+		delete(s.states, broadcastID)
+	}
+}
+
+func (s *broadcastStates) check(broadcastID string, next BroadcastState) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.states[broadcastID]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(e)
+	current := e.Value.(*broadcastStateEntry).state
+	if current.CanTransitionTo(next) {
+		return nil
+	}
+	return &TransitionError{BroadcastID: broadcastID, From: current, To: next}
+}
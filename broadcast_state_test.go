@@ -0,0 +1,94 @@
+package goperiscope
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcastStateJSON(t *testing.T) {
+
+	var b Broadcast
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"broadcast_id","state":"running"}`), &b))
+	assert.Equal(t, BroadcastStateRunning, b.State)
+	assert.True(t, b.State.IsKnown())
+	assert.True(t, b.State.IsLive())
+	assert.False(t, b.State.IsEnded())
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"broadcast_id","state":"archived"}`), &b))
+	assert.Equal(t, BroadcastState("archived"), b.State)
+	assert.False(t, b.State.IsKnown())
+	assert.True(t, b.State.CanTransitionTo(BroadcastStateRunning))
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"id":"broadcast_id","state":null}`), &b))
+	assert.Equal(t, BroadcastState(""), b.State)
+
+	out, err := json.Marshal(Broadcast{ID: "broadcast_id", State: BroadcastStateTimedOut})
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `"state":"timed_out"`)
+}
+
+func TestBroadcastStateTransition(t *testing.T) {
+
+	assert.True(t, BroadcastStateNotStarted.CanTransitionTo(BroadcastStateRunning))
+	assert.True(t, BroadcastStateRunning.CanTransitionTo(BroadcastStateEnded))
+	assert.False(t, BroadcastStateNotStarted.CanTransitionTo(BroadcastStateEnded))
+	assert.False(t, BroadcastStateEnded.CanTransitionTo(BroadcastStateRunning))
+	assert.False(t, BroadcastStateTimedOut.CanTransitionTo(BroadcastStateEnded))
+}
+
+func TestBroadcastStatesLimit(t *testing.T) {
+
+	s := newBroadcastStates()
+	s.limit = 2
+
+	s.set("1", BroadcastStateEnded)
+	s.set("2", BroadcastStateEnded)
+	assert.Error(t, s.check("1", BroadcastStateRunning))
+	s.set("3", BroadcastStateEnded)
+
+	// "2" is the least recently used one
+	assert.Len(t, s.states, 2)
+	assert.Error(t, s.check("1", BroadcastStateRunning))
+	assert.NoError(t, s.check("2", BroadcastStateRunning))
+	assert.Error(t, s.check("3", BroadcastStateRunning))
+
+	s.remove("1")
+	assert.Len(t, s.states, 1)
+	assert.Equal(t, 1, s.lru.Len())
+}
+
+func TestClientRejectsInvalidTransition(t *testing.T) {
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"broadcast_id","state":"ended","title":"title"}`))
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	_, err := c.GetBroadcast("broadcast_id")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, requests)
+
+	_, err = c.PublishBroadcast("broadcast_id", "title", false, "ja_JP", false)
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+
+	err = c.StopBroadcast("broadcast_id")
+	assert.True(t, errors.Is(err, ErrInvalidTransition))
+	assert.True(t, errors.Is(err, ErrBroadcastAlreadyStopped))
+
+	var transitionErr *TransitionError
+	if assert.True(t, errors.As(err, &transitionErr)) {
+		assert.Equal(t, BroadcastStateEnded, transitionErr.From)
+	}
+	assert.Equal(t, 1, requests)
+}
@@ -0,0 +1,293 @@
+package goperiscope
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// EventStream delivers the events of a single broadcast.
+type EventStream interface {
+	BroadcastID() string
+	// Events is closed once the stream is closed or fails.
+	Events() <-chan Event
+	// Err returns the error that ended the stream after Events is closed, or nil when it was closed by Close.
+	Err() error
+	Close() error
+}
+
+var (
+	// chatPingPeriod must be shorter than chatPongWait.
+	chatPingPeriod       = 30 * time.Second
+	chatPongWait         = 60 * time.Second
+	chatWriteWait        = 10 * time.Second
+	chatCloseGracePeriod = 5 * time.Second
+	chatMinReconnectWait = 1 * time.Second
+	chatMaxReconnectWait = 30 * time.Second
+)
+
+// ChatStream is a WebSocket connection to a broadcast's chat endpoint.
+// It reconnects with backoff until Close is called, or until the API refuses the connection for good.
+type ChatStream struct {
+	client      ClientImpl
+	broadcastID string
+	dialer      *websocket.Dialer
+	events      chan Event
+	done        chan struct{}
+	finished    chan struct{}
+	closeOnce   sync.Once
+
+	mu     sync.Mutex
+	conn   *websocket.Conn
+	closed bool
+	err    error
+}
+
+func (i ClientImpl) ConnectChat(ctx context.Context, broadcastID string) (EventStream, error) {
+	s := &ChatStream{
+		client:      i,
+		broadcastID: broadcastID,
+		dialer:      websocket.DefaultDialer,
+		events:      make(chan Event),
+		done:        make(chan struct{}),
+		finished:    make(chan struct{}),
+	}
+
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Periscope /broadcast/chat is failed")
+	}
+	s.setConn(conn)
+
+	go s.run(conn)
+	return s, nil
+}
+
+func (s *ChatStream) BroadcastID() string {
+	return s.broadcastID
+}
+
+func (s *ChatStream) Events() <-chan Event {
+	return s.events
+}
+
+func (s *ChatStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close sends a close frame and waits briefly for the server to acknowledge it before dropping the connection.
+// Between connections there is nothing to acknowledge, and Close only stops reconnecting.
+func (s *ChatStream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		// holding mu keeps run from closing conn while the close frame is written
+		s.mu.Lock()
+		s.closed = true
+		conn := s.conn
+		if conn != nil {
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			err = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(chatWriteWait))
+		}
+		s.mu.Unlock()
+
+		if conn != nil {
+			select {
+			case <-s.finished:
+			case <-time.After(chatCloseGracePeriod):
+			}
+			conn.Close()
+		}
+		<-s.finished
+	})
+	if err == websocket.ErrCloseSent {
+		return nil
+	}
+	return err
+}
+
+func (s *ChatStream) chatURL() string {
+	u := fmt.Sprintf("%s/broadcast/chat?id=%s", s.client.urlBase, s.broadcastID)
+	if strings.HasPrefix(u, "https://") {
+		return "wss://" + strings.TrimPrefix(u, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(u, "http://")
+}
+
+func (s *ChatStream) dial(ctx context.Context) (*websocket.Conn, error) {
+	accessToken, err := s.client.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", s.client.useragent)
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	conn, resp, err := s.dialer.DialContext(ctx, s.chatURL(), header)
+	if err != nil {
+		if resp == nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && s.client.tokenSource != nil {
+			s.client.tokenSource.Invalidate(accessToken)
+		}
+		return nil, newResponseError(resp, nil)
+	}
+	return conn, nil
+}
+
+func (s *ChatStream) run(conn *websocket.Conn) {
+	defer close(s.finished)
+	defer close(s.events)
+
+	for {
+		err := s.read(conn)
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+		log.Printf("chat stream is disconnected, reconnecting. broadcastID=%s, err=%v", s.broadcastID, err)
+
+		if conn = s.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+func (s *ChatStream) read(conn *websocket.Conn) error {
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go s.ping(conn, stopPing)
+
+	conn.SetReadDeadline(time.Now().Add(chatPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(chatPongWait))
+	})
+
+	for {
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		ev, err := DecodeEvent(b)
+		if err != nil {
+			log.Printf("failed to decode chat event. broadcastID=%s, err=%v", s.broadcastID, err)
+			continue
+		}
+
+		select {
+		case s.events <- ev:
+		case <-s.done:
+			// keep reading until the server acknowledges the close frame
+		}
+	}
+}
+
+func (s *ChatStream) ping(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(chatPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(chatWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// isTerminalChatError reports whether reconnecting cannot help, e.g. because the broadcast has ended.
+// A first 401 is retried, as dial has invalidated the access token.
+func isTerminalChatError(err error, unauthorizedBefore bool) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return unauthorizedBefore
+	case e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests:
+		return false
+	}
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// reconnect returns nil when the stream is closed before a connection is established,
+// or when the API refuses the connection for good, in which case the error is kept for Err.
+func (s *ChatStream) reconnect() *websocket.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	wait := chatMinReconnectWait
+	unauthorized := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		conn, err := s.dial(ctx)
+		if err == nil {
+			if !s.setConn(conn) {
+				conn.Close()
+				return nil
+			}
+			return conn
+		}
+		if isTerminalChatError(err, unauthorized) {
+			log.Printf("chat stream is refused, giving up. broadcastID=%s, err=%v", s.broadcastID, err)
+			s.mu.Lock()
+			s.err = errors.Wrapf(err, "Periscope /broadcast/chat is failed")
+			s.mu.Unlock()
+			return nil
+		}
+		unauthorized = errors.Is(err, ErrUnauthorized)
+		log.Printf("failed to reconnect chat stream. broadcastID=%s, err=%v", s.broadcastID, err)
+
+		wait *= 2
+		if wait > chatMaxReconnectWait {
+			wait = chatMaxReconnectWait
+		}
+	}
+}
+
+// setConn reports false when the stream was closed meanwhile.
+func (s *ChatStream) setConn(conn *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conn = conn
+	return true
+}
@@ -0,0 +1,175 @@
+package goperiscope
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatStream(t *testing.T) {
+
+	minWait := chatMinReconnectWait
+	chatMinReconnectWait = time.Millisecond
+	defer func() { chatMinReconnectWait = minWait }()
+
+	var mu sync.Mutex
+	connections := 0
+
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correctPath := "/broadcast/chat"
+		if r.URL.Path != correctPath {
+			t.Errorf("r.URL.Path ='%v', want '%v'", r.URL.Path, correctPath)
+		}
+		assert.Equal(t, "broadcast_id", r.URL.Query().Get("id"))
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		mu.Lock()
+		connections++
+		n := connections
+		mu.Unlock()
+
+		if n == 1 {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"1","type":"chat","text":"hello","user":{"id":"1111","username":"hoge"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"2","type":"unknown_type"}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"3","type":"viewer_count","live":10,"total":20}`))
+			// drop the connection without a close frame
+			return
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"4","type":"heart","user":{"id":"2222"}}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	s, err := c.ConnectChat(context.Background(), "broadcast_id")
+	assert.NoError(t, err)
+	assert.Equal(t, "broadcast_id", s.BroadcastID())
+
+	chat := (<-s.Events()).(ChatMessage)
+	assert.Equal(t, "hello", chat.Text)
+	assert.Equal(t, "1111", chat.User.ID)
+
+	unknown := (<-s.Events()).(UnknownEvent)
+	assert.Equal(t, "unknown_type", unknown.Type)
+
+	viewerCount := (<-s.Events()).(ViewerCountMessage)
+	assert.Equal(t, int32(10), viewerCount.Live)
+	assert.Equal(t, int32(20), viewerCount.Total)
+
+	// delivered after reconnecting
+	heart := (<-s.Events()).(HeartMessage)
+	assert.Equal(t, "2222", heart.User.ID)
+
+	assert.NoError(t, s.Close())
+	_, ok := <-s.Events()
+	assert.False(t, ok)
+	assert.NoError(t, s.Err())
+}
+
+// chatServer accepts the first connection, sends one chat message and drops it, then answers every reconnect with status.
+func chatServer(t *testing.T, status int) (*httptest.Server, *int32) {
+	var dials int32
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dials, 1) > 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"message":"` + http.StatusText(status) + `"}`))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"1","type":"chat","text":"bye"}`))
+		conn.Close()
+	}))
+	return ts, &dials
+}
+
+func TestChatStreamEnded(t *testing.T) {
+
+	minWait := chatMinReconnectWait
+	chatMinReconnectWait = time.Millisecond
+	defer func() { chatMinReconnectWait = minWait }()
+
+	for _, status := range []int{http.StatusNotFound, http.StatusUnauthorized} {
+		ts, dials := chatServer(t, status)
+
+		s, err := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token").ConnectChat(context.Background(), "broadcast_id")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "bye", (<-s.Events()).(ChatMessage).Text)
+		_, ok := <-s.Events()
+		assert.False(t, ok, "stream ends once the broadcast is gone")
+		assert.Error(t, s.Err())
+		assert.NoError(t, s.Close())
+		ts.Close()
+
+		if status == http.StatusNotFound {
+			assert.True(t, errors.Is(s.Err(), ErrNotFound))
+			assert.Equal(t, int32(2), atomic.LoadInt32(dials))
+		} else {
+			// a 401 is retried once with a refreshed token
+			assert.True(t, errors.Is(s.Err(), ErrUnauthorized))
+			assert.Equal(t, int32(3), atomic.LoadInt32(dials))
+		}
+	}
+}
+
+func TestChatStreamCloseWhileReconnecting(t *testing.T) {
+
+	minWait := chatMinReconnectWait
+	chatMinReconnectWait = time.Millisecond
+	defer func() { chatMinReconnectWait = minWait }()
+
+	ts, dials := chatServer(t, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	s, err := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token").ConnectChat(context.Background(), "broadcast_id")
+	assert.NoError(t, err)
+	<-s.Events()
+
+	for atomic.LoadInt32(dials) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.NoError(t, s.Close())
+	assert.NoError(t, s.Err())
+}
+
+func TestChatStreamUnauthorized(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"invalid token"}`))
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	_, err := c.ConnectChat(context.Background(), "broadcast_id")
+	assert.True(t, isUnauthorized(err))
+}
@@ -2,10 +2,15 @@ package goperiscope
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,6 +22,10 @@ type PeriscopeBuilder struct {
 	clientID     string
 	clientSecret string
 	refreshToken string
+	tokenStore   TokenStore
+	authorizeURL string
+	retryPolicy  *RetryPolicy
+	rateLimiter  *RateLimiter
 }
 
 func NewBuilder(urlBase, useragent, clientID, clientSecret string) PeriscopeBuilder {
@@ -33,36 +42,112 @@ func (b *PeriscopeBuilder) RefreshToken(t string) *PeriscopeBuilder {
 	return b
 }
 
+func (b *PeriscopeBuilder) TokenStore(s TokenStore) *PeriscopeBuilder {
+	b.tokenStore = s
+	return b
+}
+
+func (b *PeriscopeBuilder) AuthorizeURL(u string) *PeriscopeBuilder {
+	b.authorizeURL = u
+	return b
+}
+
+func (b *PeriscopeBuilder) RetryPolicy(p RetryPolicy) *PeriscopeBuilder {
+	b.retryPolicy = &p
+	return b
+}
+
+func (b *PeriscopeBuilder) RateLimiter(l *RateLimiter) *PeriscopeBuilder {
+	b.rateLimiter = l
+	return b
+}
+
+func (b *PeriscopeBuilder) BuildAuthClient() AuthClient {
+
+	httpCli := http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	return newAuthClient(b.urlBase, b.authorizeURL, &httpCli, b.useragent, b.clientID, b.clientSecret)
+}
+
 func (b *PeriscopeBuilder) BuildClient() (Client, error) {
+	return b.BuildClientContext(context.Background())
+}
+
+func (b *PeriscopeBuilder) BuildClientContext(ctx context.Context) (Client, error) {
 
 	httpCli := http.Client{
 		Timeout: 10 * time.Second,
 	}
 
-	authCli := newAuthClient(b.urlBase, &httpCli, b.useragent, b.clientID, b.clientSecret)
-	auth, err := authCli.OAuthRefresh(b.refreshToken)
+	authCli := newAuthClient(b.urlBase, b.authorizeURL, &httpCli, b.useragent, b.clientID, b.clientSecret)
+	store, err := b.buildTokenStore()
 	if err != nil {
+		return nil, err
+	}
+	tokenSource := NewStoreTokenSource(authCli, store)
+	if _, err := tokenSource.Token(ctx); err != nil {
 		return nil, errors.Wrapf(err, "OAuthRefresh is failed")
 	}
 
-	return NewClient(b.urlBase, &httpCli, b.useragent, auth.AccessToken), nil
+	return &ClientImpl{
+		urlBase:     b.urlBase,
+		httpCli:     &httpCli,
+		useragent:   b.useragent,
+		tokenSource: tokenSource,
+		retryPolicy: b.retryPolicy,
+		rateLimiter: b.rateLimiter,
+		states:      newBroadcastStates(),
+	}, nil
 }
 
+func (b *PeriscopeBuilder) buildTokenStore() (TokenStore, error) {
+	if b.tokenStore == nil {
+		return NewMemoryTokenStore(b.refreshToken), nil
+	}
+
+	// a refresh token already in the store may have been rotated, so it wins over the configured one.
+	token, err := b.tokenStore.Load()
+	if err != nil {
+		return nil, errors.Wrapf(err, "TokenStore.Load is failed")
+	}
+	if token.RefreshToken == "" && b.refreshToken != "" {
+		token.RefreshToken = b.refreshToken
+		if err := b.tokenStore.Save(token); err != nil {
+			return nil, errors.Wrapf(err, "TokenStore.Save is failed")
+		}
+	}
+	return b.tokenStore, nil
+}
+
+// DefaultAuthorizeURL is the page users are sent to for granting access in the authorization-code flow.
+const DefaultAuthorizeURL = "https://www.pscp.tv/oauth"
+
 type AuthClient interface {
 	OAuthRefresh(refreshToken string) (*OAuthRefreshResponse, error)
+	OAuthRefreshContext(ctx context.Context, refreshToken string) (*OAuthRefreshResponse, error)
+	AuthorizeURL(redirectURI string, state string) string
+	OAuthExchange(code string, redirectURI string) (*OAuthRefreshResponse, error)
+	OAuthExchangeContext(ctx context.Context, code string, redirectURI string) (*OAuthRefreshResponse, error)
+	RequestDeviceCode() (*DeviceCodeResponse, error)
+	RequestDeviceCodeContext(ctx context.Context) (*DeviceCodeResponse, error)
+	PollDeviceToken(ctx context.Context, deviceCode *DeviceCodeResponse) (*OAuthRefreshResponse, error)
 }
 
 type AuthClientImpl struct {
 	urlBase      string
+	authorizeURL string
 	httpCli      *http.Client
 	useragent    string
 	clientID     string
 	clientSecret string
 }
 
-func newAuthClient(urlBase string, httpCli *http.Client, useragent string, clientID string, clientSecret string) AuthClient {
+func newAuthClient(urlBase string, authorizeURL string, httpCli *http.Client, useragent string, clientID string, clientSecret string) AuthClient {
 	return &AuthClientImpl{
 		urlBase:      urlBase,
+		authorizeURL: authorizeURL,
 		httpCli:      httpCli,
 		useragent:    useragent,
 		clientID:     clientID,
@@ -71,6 +156,10 @@ func newAuthClient(urlBase string, httpCli *http.Client, useragent string, clien
 }
 
 func (i AuthClientImpl) OAuthRefresh(refreshToken string) (*OAuthRefreshResponse, error) {
+	return i.OAuthRefreshContext(context.Background(), refreshToken)
+}
+
+func (i AuthClientImpl) OAuthRefreshContext(ctx context.Context, refreshToken string) (*OAuthRefreshResponse, error) {
 	req := OAuthRefreshRequest{
 		GrantType:    "refresh_token",
 		ClientID:     i.clientID,
@@ -79,14 +168,48 @@ func (i AuthClientImpl) OAuthRefresh(refreshToken string) (*OAuthRefreshResponse
 	}
 
 	var result OAuthRefreshResponse
-	if err := i.request("POST", "/oauth/token", req, &result); err != nil {
+	if err := i.request(ctx, "POST", "/oauth/token", req, &result); err != nil {
 		return nil, errors.Wrapf(err, "Periscope /oauth/token is failed")
 	}
 
 	return &result, nil
 }
 
-func (c AuthClientImpl) request(method, path string, params interface{}, result interface{}) error {
+func (i AuthClientImpl) AuthorizeURL(redirectURI string, state string) string {
+	authorizeURL := i.authorizeURL
+	if authorizeURL == "" {
+		authorizeURL = DefaultAuthorizeURL
+	}
+
+	q := url.Values{}
+	q.Set("client_id", i.clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("state", state)
+	return fmt.Sprintf("%s?%s", authorizeURL, q.Encode())
+}
+
+func (i AuthClientImpl) OAuthExchange(code string, redirectURI string) (*OAuthRefreshResponse, error) {
+	return i.OAuthExchangeContext(context.Background(), code, redirectURI)
+}
+
+func (i AuthClientImpl) OAuthExchangeContext(ctx context.Context, code string, redirectURI string) (*OAuthRefreshResponse, error) {
+	req := OAuthExchangeRequest{
+		GrantType:    "authorization_code",
+		ClientID:     i.clientID,
+		ClientSecret: i.clientSecret,
+		Code:         code,
+		RedirectURI:  redirectURI,
+	}
+
+	var result OAuthRefreshResponse
+	if err := i.request(ctx, "POST", "/oauth/token", req, &result); err != nil {
+		return nil, errors.Wrapf(err, "Periscope /oauth/token is failed")
+	}
+
+	return &result, nil
+}
+
+func (c AuthClientImpl) request(ctx context.Context, method, path string, params interface{}, result interface{}) error {
 
 	headers := map[string]string{
 		"User-Agent": c.useragent,
@@ -101,6 +224,7 @@ func (c AuthClientImpl) request(method, path string, params interface{}, result
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/json")
 	for name, value := range headers {
 		req.Header.Set(name, value)
@@ -109,6 +233,9 @@ func (c AuthClientImpl) request(method, path string, params interface{}, result
 	// request
 	resp, err := c.httpCli.Do(req)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return err
 	}
 	defer func() {
@@ -121,14 +248,7 @@ func (c AuthClientImpl) request(method, path string, params interface{}, result
 	if resp.StatusCode >= 300 {
 		log.Printf("unexpected API Response. statusCode=%d, url=%s", resp.StatusCode, apiURL)
 
-		internalErr := internalError{}
-
-		if err := json.NewDecoder(resp.Body).Decode(&internalErr); err != nil {
-			return fmt.Errorf(
-				"JSON parse error [statusCode='%d', err='%v']", resp.StatusCode, err,
-			)
-		}
-		return NewError(resp.StatusCode, params.(fmt.Stringer), internalErr)
+		return newResponseError(resp, params)
 	}
 
 	if result == nil {
@@ -136,6 +256,9 @@ func (c AuthClientImpl) request(method, path string, params interface{}, result
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return fmt.Errorf(
 			"JSON parse error [statusCode='%d', err='%v']", resp.StatusCode, err,
 		)
@@ -146,11 +269,18 @@ func (c AuthClientImpl) request(method, path string, params interface{}, result
 
 type Client interface {
 	GetRegion() (*GetRegionResponse, error)
+	GetRegionContext(ctx context.Context) (*GetRegionResponse, error)
 	CreateBroadcast(region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error)
+	CreateBroadcastContext(ctx context.Context, region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error)
 	PublishBroadcast(broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error)
+	PublishBroadcastContext(ctx context.Context, broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error)
 	StopBroadcast(broadcastID string) error
+	StopBroadcastContext(ctx context.Context, broadcastID string) error
 	GetBroadcast(broadcastID string) (*Broadcast, error)
+	GetBroadcastContext(ctx context.Context, broadcastID string) (*Broadcast, error)
 	DeleteBroadcast(broadcastID string) error
+	DeleteBroadcastContext(ctx context.Context, broadcastID string) error
+	ConnectChat(ctx context.Context, broadcastID string) (EventStream, error)
 }
 
 type ClientImpl struct {
@@ -158,6 +288,10 @@ type ClientImpl struct {
 	httpCli     *http.Client
 	useragent   string
 	accessToken string
+	tokenSource TokenSource
+	retryPolicy *RetryPolicy
+	rateLimiter *RateLimiter
+	states      *broadcastStates
 }
 
 func NewClient(urlBase string, httpCli *http.Client, useragent string, accessToken string) Client {
@@ -166,19 +300,38 @@ func NewClient(urlBase string, httpCli *http.Client, useragent string, accessTok
 		httpCli:     httpCli,
 		useragent:   useragent,
 		accessToken: accessToken,
+		states:      newBroadcastStates(),
+	}
+}
+
+func NewClientWithTokenSource(urlBase string, httpCli *http.Client, useragent string, tokenSource TokenSource) Client {
+	return &ClientImpl{
+		urlBase:     urlBase,
+		httpCli:     httpCli,
+		useragent:   useragent,
+		tokenSource: tokenSource,
+		states:      newBroadcastStates(),
 	}
 }
 
 func (i ClientImpl) GetRegion() (*GetRegionResponse, error) {
+	return i.GetRegionContext(context.Background())
+}
+
+func (i ClientImpl) GetRegionContext(ctx context.Context) (*GetRegionResponse, error) {
 
 	var result GetRegionResponse
-	if err := i.request("GET", "/region", nil, &result); err != nil {
+	if err := i.request(ctx, "GET", "/region", nil, &result); err != nil {
 		return nil, errors.Wrapf(err, "Periscope /region is failed")
 	}
 	return &result, nil
 }
 
 func (i ClientImpl) CreateBroadcast(region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error) {
+	return i.CreateBroadcastContext(context.Background(), region, is360, isLowLatency)
+}
+
+func (i ClientImpl) CreateBroadcastContext(ctx context.Context, region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error) {
 
 	req := CreateBroadcastRequest{
 		Region:       region,
@@ -187,14 +340,23 @@ func (i ClientImpl) CreateBroadcast(region string, is360 bool, isLowLatency bool
 	}
 
 	var result CreateBroadcastResponse
-	if err := i.request("POST", "/broadcast/create", req, &result); err != nil {
+	if err := i.request(ctx, "POST", "/broadcast/create", req, &result); err != nil {
 		return nil, errors.Wrapf(err, "Periscope /broadcast/create is failed")
 	}
+	i.states.set(result.Broadcast.ID, result.Broadcast.State)
 
 	return &result, nil
 }
 
 func (i ClientImpl) PublishBroadcast(broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error) {
+	return i.PublishBroadcastContext(context.Background(), broadcastID, title, withTweet, locale, enableSuperHearts)
+}
+
+func (i ClientImpl) PublishBroadcastContext(ctx context.Context, broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error) {
+
+	if err := i.states.check(broadcastID, BroadcastStateRunning); err != nil {
+		return nil, errors.Wrapf(err, "Periscope /broadcast/publish is rejected")
+	}
 
 	req := PublishBroadcastRequest{
 		BroadcastID:       broadcastID,
@@ -205,70 +367,152 @@ func (i ClientImpl) PublishBroadcast(broadcastID string, title string, withTweet
 	}
 
 	var result PublishBroadcastResponse
-	if err := i.request("POST", "/broadcast/publish", req, &result); err != nil {
+	if err := i.request(ctx, "POST", "/broadcast/publish", req, &result); err != nil {
 		return nil, errors.Wrapf(err, "Periscope /broadcast/publish is failed")
 	}
+	i.states.set(result.Broadcast.ID, result.Broadcast.State)
 
 	return &result, nil
 }
 
 func (i ClientImpl) StopBroadcast(broadcastID string) error {
+	return i.StopBroadcastContext(context.Background(), broadcastID)
+}
+
+func (i ClientImpl) StopBroadcastContext(ctx context.Context, broadcastID string) error {
+
+	if err := i.states.check(broadcastID, BroadcastStateEnded); err != nil {
+		return errors.Wrapf(err, "Periscope /broadcast/stop is rejected")
+	}
 
 	req := StopBroadcastRequest{
 		BroadcastID: broadcastID,
 	}
 
-	if err := i.request("POST", "/broadcast/stop", req, nil); err != nil {
+	if err := i.request(ctx, "POST", "/broadcast/stop", req, nil); err != nil {
 		return errors.Wrapf(err, "Periscope /broadcast/stop is failed")
 	}
+	i.states.set(broadcastID, BroadcastStateEnded)
 
 	return nil
 }
 
 func (i ClientImpl) GetBroadcast(broadcastID string) (*Broadcast, error) {
+	return i.GetBroadcastContext(context.Background(), broadcastID)
+}
+
+func (i ClientImpl) GetBroadcastContext(ctx context.Context, broadcastID string) (*Broadcast, error) {
 	var result Broadcast
-	if err := i.request("GET", fmt.Sprintf("/broadcast?id=%s", broadcastID), nil, &result); err != nil {
-		return nil, errors.Wrapf(err, "Periscope /region is failed")
+	if err := i.request(ctx, "GET", fmt.Sprintf("/broadcast?id=%s", broadcastID), nil, &result); err != nil {
+		return nil, errors.Wrapf(err, "Periscope /broadcast is failed")
 	}
+	i.states.set(result.ID, result.State)
 	return &result, nil
 }
 
 func (i ClientImpl) DeleteBroadcast(broadcastID string) error {
+	return i.DeleteBroadcastContext(context.Background(), broadcastID)
+}
+
+func (i ClientImpl) DeleteBroadcastContext(ctx context.Context, broadcastID string) error {
 	req := DeleteBroadcastRequest{
 		BroadcastID: broadcastID,
 	}
 
-	if err := i.request("POST", "/broadcast/delete", req, nil); err != nil {
+	if err := i.request(ctx, "POST", "/broadcast/delete", req, nil); err != nil {
 		return errors.Wrapf(err, "Periscope /broadcast/delete is failed")
 	}
+	i.states.remove(broadcastID)
 
 	return nil
 }
 
-func (c ClientImpl) request(method, path string, params interface{}, result interface{}) error {
+func (c ClientImpl) request(ctx context.Context, method, path string, params interface{}, result interface{}) error {
 
-	headers := map[string]string{
-		"User-Agent":    c.useragent,
-		"Authorization": fmt.Sprintf("Bearer %s", c.accessToken),
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
 	}
 
-	body, err := json.Marshal(params)
+	accessToken, err := c.token(ctx)
 	if err != nil {
 		return err
 	}
+
+	err = c.doWithRetry(ctx, method, path, body, accessToken, params, result)
+	if c.tokenSource == nil || !isUnauthorized(err) {
+		return err
+	}
+
+	// the access token was rejected before its expiry, so refresh it and retry once.
+	c.tokenSource.Invalidate(accessToken)
+	if accessToken, err = c.token(ctx); err != nil {
+		return err
+	}
+	return c.doWithRetry(ctx, method, path, body, accessToken, params, result)
+}
+
+func (c ClientImpl) doWithRetry(ctx context.Context, method, path string, body []byte, accessToken string, params interface{}, result interface{}) error {
+	if c.retryPolicy == nil || !c.retryPolicy.allows(method, path) {
+		return c.do(ctx, method, path, body, accessToken, params, result)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.do(ctx, method, path, body, accessToken, params, result)
+		if err == nil {
+			return nil
+		}
+		if attempt >= c.retryPolicy.MaxAttempts || !c.retryPolicy.retryable(err) {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		timer := time.NewTimer(c.retryPolicy.delay(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &RetryError{Attempts: attempt, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+func (c ClientImpl) token(ctx context.Context) (string, error) {
+	if c.tokenSource == nil {
+		return c.accessToken, nil
+	}
+	return c.tokenSource.Token(ctx)
+}
+
+func (c ClientImpl) do(ctx context.Context, method, path string, body []byte, accessToken string, params interface{}, result interface{}) error {
+
+	headers := map[string]string{
+		"User-Agent":    c.useragent,
+		"Authorization": fmt.Sprintf("Bearer %s", accessToken),
+	}
+
 	apiURL := fmt.Sprintf("%s%s", c.urlBase, path)
 	req, err := http.NewRequest(method, apiURL, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/json")
 	for name, value := range headers {
 		req.Header.Set(name, value)
 	}
 
+	if c.rateLimiter != nil {
+		if err := c.rateLimiter.Wait(ctx, path); err != nil {
+			return err
+		}
+	}
+
 	// request
 	resp, err := c.httpCli.Do(req)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return err
 	}
 	defer func() {
@@ -277,18 +521,15 @@ func (c ClientImpl) request(method, path string, params interface{}, result inte
 		}
 	}()
 
+	if c.rateLimiter != nil {
+		c.rateLimiter.Update(path, resp.Header)
+	}
+
 	// error handling for status code
 	if resp.StatusCode >= 300 {
 		log.Printf("unexpected API Response. statusCode=%d, url=%s", resp.StatusCode, apiURL)
 
-		internalErr := internalError{}
-
-		if err := json.NewDecoder(resp.Body).Decode(&internalErr); err != nil {
-			return fmt.Errorf(
-				"JSON parse error [statusCode='%d', err='%v']", resp.StatusCode, err,
-			)
-		}
-		return NewError(resp.StatusCode, params.(fmt.Stringer), internalErr)
+		return newResponseError(resp, params)
 	}
 
 	if result == nil {
@@ -296,6 +537,9 @@ func (c ClientImpl) request(method, path string, params interface{}, result inte
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return fmt.Errorf(
 			"JSON parse error [statusCode='%d', err='%v']", resp.StatusCode, err,
 		)
@@ -303,3 +547,46 @@ func (c ClientImpl) request(method, path string, params interface{}, result inte
 
 	return nil
 }
+
+// maxErrorBodySize bounds how much of an error response is read, and errorBodySnippetSize how much of it is kept in Error.
+const (
+	maxErrorBodySize     = 64 * 1024
+	errorBodySnippetSize = 512
+)
+
+// newResponseError builds an Error from a non-2xx response. The body may be JSON, an HTML error page or empty.
+func newResponseError(resp *http.Response, params interface{}) error {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		log.Printf("failed to read error response. statusCode=%d, err=%v", resp.StatusCode, err)
+	}
+
+	internalErr := internalError{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &internalErr); err != nil {
+			internalErr = internalError{}
+		}
+	}
+
+	if len(body) > errorBodySnippetSize {
+		body = body[:errorBodySnippetSize]
+	}
+
+	// GET requests have no params
+	stringer, _ := params.(fmt.Stringer)
+	e := NewError(resp.StatusCode, stringer, internalErr).(*Error)
+	e.Header = resp.Header
+	e.RequestID = requestID(resp.Header)
+	e.Body = strings.ToValidUTF8(string(body), "")
+	e.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return e
+}
+
+func requestID(header http.Header) string {
+	for _, name := range []string{"X-Request-Id", "X-Amzn-Requestid", "X-Transaction-Id"} {
+		if v := header.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
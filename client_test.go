@@ -1,11 +1,14 @@
 package goperiscope
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -156,7 +159,7 @@ func TestCreateBroadcast(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, "hogehogehoge", result.Broadcast.ID)
-	assert.Equal(t, "not_started", result.Broadcast.State)
+	assert.Equal(t, BroadcastStateNotStarted, result.Broadcast.State)
 	assert.Equal(t, "https://api.pscp.tv/v1/hls?token=hogehogehoge", result.VideoAccess.HlsURL)
 	assert.Equal(t, "https://www.pscp.tv/w/hogehogehoge", result.ShareURL)
 	assert.Equal(t, "hoge_stream_key", result.Encoder.StreamKey)
@@ -200,7 +203,7 @@ func TestGetBroadcast(t *testing.T) {
 	result, err := c.GetBroadcast("broadcast_id")
 	assert.NoError(t, err)
 	assert.Equal(t, "broadcast_id", result.ID)
-	assert.Equal(t, "not_started", result.State)
+	assert.Equal(t, BroadcastStateNotStarted, result.State)
 	assert.Equal(t, "title", result.Title)
 }
 
@@ -244,7 +247,7 @@ func TestPublishBroadcast(t *testing.T) {
 	result, err := c.PublishBroadcast("broadcast_id", "title", false, "ja_JP", true)
 	assert.NoError(t, err)
 	assert.Equal(t, "broadcast_id", result.Broadcast.ID)
-	assert.Equal(t, "running", result.Broadcast.State)
+	assert.Equal(t, BroadcastStateRunning, result.Broadcast.State)
 	assert.Equal(t, "title", result.Broadcast.Title)
 }
 
@@ -322,3 +325,29 @@ func TestDeleteBroadcast(t *testing.T) {
 	err := c.DeleteBroadcast("broadcast_id")
 	assert.NoError(t, err)
 }
+
+func TestCreateBroadcastContextCanceled(t *testing.T) {
+
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	httpCli := &http.Client{}
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     httpCli,
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result, err := c.CreateBroadcastContext(ctx, "ap-northeast-1", false, true)
+	assert.Nil(t, result)
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+}
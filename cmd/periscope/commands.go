@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func runRegion(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("region")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := client.GetRegionContext(ctx)
+	if err != nil {
+		return err
+	}
+	return c.print(result, [][2]string{{"REGION", result.Region}})
+}
+
+func runCreate(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("create")
+	region := fs.String("region", "", "ingest region (default: the region suggested by the API)")
+	is360 := fs.Bool("360", false, "create a 360 degree broadcast")
+	isLowLatency := fs.Bool("low-latency", false, "create a low latency broadcast")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	if *region == "" {
+		result, err := client.GetRegionContext(ctx)
+		if err != nil {
+			return err
+		}
+		*region = result.Region
+	}
+
+	result, err := client.CreateBroadcastContext(ctx, *region, *is360, *isLowLatency)
+	if err != nil {
+		return err
+	}
+	rows := broadcastRows(result.Broadcast)
+	rows = append(rows, [2]string{"SHARE URL", result.ShareURL})
+	rows = append(rows, encoderRows(result.Encoder)...)
+	return c.print(result, rows)
+}
+
+func runPublish(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("publish")
+	title := fs.String("title", "", "broadcast title")
+	locale := fs.String("locale", "", "broadcast locale, e.g. en_US")
+	withTweet := fs.Bool("tweet", false, "tweet the broadcast")
+	superHearts := fs.Bool("super-hearts", false, "enable super hearts")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := client.PublishBroadcastContext(ctx, fs.Arg(0), *title, *withTweet, *locale, *superHearts)
+	if err != nil {
+		return err
+	}
+	return c.print(result, broadcastRows(result.Broadcast))
+}
+
+func runStop(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("stop")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.StopBroadcastContext(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+	return c.printStatus(fs.Arg(0), "stopped")
+}
+
+func runGet(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("get")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := client.GetBroadcastContext(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return c.print(result, broadcastRows(*result))
+}
+
+func runDelete(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("delete")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteBroadcastContext(ctx, fs.Arg(0)); err != nil {
+		return err
+	}
+	return c.printStatus(fs.Arg(0), "deleted")
+}
+
+func runToken(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("token")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+	if fs.Arg(0) != "refresh" {
+		return usagef("unknown token command %q", fs.Arg(0))
+	}
+
+	b, err := c.config.builder()
+	if err != nil {
+		return err
+	}
+	store := c.config.tokenStore()
+	token, err := store.Load()
+	if err != nil {
+		return errors.Wrapf(err, "TokenStore.Load is failed")
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = c.config.RefreshToken
+	}
+	if token.RefreshToken == "" {
+		return errors.New("refresh_token is required (PERISCOPE_REFRESH_TOKEN)")
+	}
+
+	result, err := b.BuildAuthClient().OAuthRefreshContext(ctx, token.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	token.AccessToken = result.AccessToken
+	if result.RefreshToken != "" {
+		token.RefreshToken = result.RefreshToken
+	}
+	token.Expiry = time.Time{}
+	if result.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	if err := store.Save(token); err != nil {
+		return errors.Wrapf(err, "TokenStore.Save is failed")
+	}
+
+	return c.print(result, [][2]string{
+		{"ACCESS TOKEN", result.AccessToken},
+		{"REFRESH TOKEN", token.RefreshToken},
+		{"EXPIRES IN", formatSeconds(result.ExpiresIn)},
+		{"USER", result.User.Username},
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/pkg/errors"
+)
+
+const defaultURLBase = "https://public-api.pscp.tv/v1"
+
+// config holds the credentials. Values from the environment override the config file.
+type config struct {
+	URLBase      string `json:"url_base"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+	// TokenFile keeps rotated refresh tokens between runs when set.
+	TokenFile string `json:"token_file"`
+}
+
+var configEnv = []struct {
+	name  string
+	field func(c *config) *string
+}{
+	{"PERISCOPE_URL_BASE", func(c *config) *string { return &c.URLBase }},
+	{"PERISCOPE_CLIENT_ID", func(c *config) *string { return &c.ClientID }},
+	{"PERISCOPE_CLIENT_SECRET", func(c *config) *string { return &c.ClientSecret }},
+	{"PERISCOPE_REFRESH_TOKEN", func(c *config) *string { return &c.RefreshToken }},
+	{"PERISCOPE_TOKEN_FILE", func(c *config) *string { return &c.TokenFile }},
+}
+
+func defaultConfigPath(getenv func(string) string) string {
+	if p := getenv("PERISCOPE_CONFIG"); p != "" {
+		return p
+	}
+	if home := getenv("HOME"); home != "" {
+		return filepath.Join(home, ".periscope.json")
+	}
+	return ""
+}
+
+// loadConfig reads path if it exists and applies the environment on top.
+// A missing file is only an error when the path was given explicitly.
+func loadConfig(path string, explicit bool, getenv func(string) string) (*config, error) {
+	c := &config{}
+
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(b, c); err != nil {
+				return nil, errors.Wrapf(err, "config file %s is broken", path)
+			}
+		case os.IsNotExist(err) && !explicit:
+		default:
+			return nil, errors.Wrapf(err, "config file %s could not be read", path)
+		}
+	}
+
+	for _, e := range configEnv {
+		if v := getenv(e.name); v != "" {
+			*e.field(c) = v
+		}
+	}
+	if c.URLBase == "" {
+		c.URLBase = defaultURLBase
+	}
+	return c, nil
+}
+
+func (c *config) builder() (*goperiscope.PeriscopeBuilder, error) {
+	if c.ClientID == "" || c.ClientSecret == "" {
+		return nil, errors.New("client_id and client_secret are required (PERISCOPE_CLIENT_ID, PERISCOPE_CLIENT_SECRET)")
+	}
+
+	b := goperiscope.NewBuilder(c.URLBase, "goperiscope-cli", c.ClientID, c.ClientSecret)
+	b.RefreshToken(c.RefreshToken)
+	if c.TokenFile != "" {
+		b.TokenStore(goperiscope.NewFileTokenStore(c.TokenFile))
+	}
+	b.RetryPolicy(goperiscope.DefaultRetryPolicy())
+	return &b, nil
+}
+
+func (c *config) tokenStore() goperiscope.TokenStore {
+	if c.TokenFile != "" {
+		return goperiscope.NewFileTokenStore(c.TokenFile)
+	}
+	return goperiscope.NewMemoryTokenStore(c.RefreshToken)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+)
+
+const liveStopTimeout = 30 * time.Second
+
+// runLive walks through going live with a BroadcastSession: create a broadcast, wait for the encoder, publish,
+// and stop on Ctrl-C. A broadcast that never went live is deleted again.
+func runLive(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("live")
+	region := fs.String("region", "", "ingest region (default: the region suggested by the API)")
+	is360 := fs.Bool("360", false, "create a 360 degree broadcast")
+	isLowLatency := fs.Bool("low-latency", false, "create a low latency broadcast")
+	title := fs.String("title", "", "broadcast title (prompted when empty)")
+	locale := fs.String("locale", "", "broadcast locale (prompted when empty)")
+	withTweet := fs.Bool("tweet", false, "tweet the broadcast")
+	superHearts := fs.Bool("super-hearts", false, "enable super hearts")
+	timeout := fs.Duration("timeout", 5*time.Minute, "how long to wait for the encoder")
+	poll := fs.Duration("poll", 2*time.Second, "how often to check the encoder")
+	profilePath := fs.String("profile", "", "encoder profile JSON to check against the recommended configuration")
+	probe := fs.Bool("probe", false, "check that the RTMPS ingest server accepts connections before waiting for the encoder")
+	if err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	var profile *goperiscope.StreamConfiguration
+	if *profilePath != "" {
+		profile = &goperiscope.StreamConfiguration{}
+		if err := readJSONFile(*profilePath, profile); err != nil {
+			return err
+		}
+	}
+
+	client, err := c.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sp *spinner
+	stopSpinner := func() {
+		if sp != nil {
+			sp.stop()
+			sp = nil
+		}
+	}
+	defer stopSpinner()
+
+	in := bufio.NewReader(c.stdin)
+	session := goperiscope.NewBroadcastSession(client, goperiscope.BroadcastSessionConfig{
+		Region:            *region,
+		Is360:             *is360,
+		IsLowLatency:      *isLowLatency,
+		WithTweet:         *withTweet,
+		EnableSuperHearts: *superHearts,
+		PollInterval:      *poll,
+		EncoderTimeout:    *timeout,
+		OnEncoderReady: func(created *goperiscope.CreateBroadcastResponse) error {
+			fmt.Fprintf(c.stdout, "Broadcast %s is created. Point your encoder at:\n\n", created.Broadcast.ID)
+			if err := c.print(created.Encoder, encoderRows(created.Encoder)); err != nil {
+				return err
+			}
+			fmt.Fprintln(c.stdout)
+
+			if profile != nil {
+				issues := created.Encoder.Validate(*profile)
+				c.printIssues(issues)
+				if err := issues.Err(); err != nil {
+					return err
+				}
+			}
+
+			if *probe {
+				result, err := created.Encoder.Probe(ctx, true)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(c.stdout, "Ingest %s answered %s in %s.\n", result.Addr, result.Code, result.Total)
+			}
+
+			sp = startSpinner(c.stderr, "Waiting for the encoder stream")
+			return nil
+		},
+		OnStateChange: func(state goperiscope.SessionState) {
+			switch state {
+			case goperiscope.SessionEncoderActive:
+				stopSpinner()
+				fmt.Fprintln(c.stdout, "Encoder stream is active.")
+			case goperiscope.SessionFailed:
+				stopSpinner()
+			}
+		},
+		BeforePublish: func(ctx context.Context) (string, string, error) {
+			var err error
+			if *title == "" {
+				if *title, err = c.prompt(ctx, in, "Title"); err != nil {
+					return "", "", err
+				}
+			}
+			if *locale == "" {
+				if *locale, err = c.prompt(ctx, in, "Locale"); err != nil {
+					return "", "", err
+				}
+			}
+			return *title, *locale, nil
+		},
+	})
+
+	// a broadcast that never went live is deleted by the session
+	if err := session.Start(ctx); err != nil {
+		return err
+	}
+	created := session.Broadcast()
+	fmt.Fprintf(c.stdout, "Broadcast %s is live: %s\nPress Ctrl-C to stop.\n", created.Broadcast.ID, created.ShareURL)
+
+	<-ctx.Done()
+	fmt.Fprintln(c.stdout)
+
+	// the session is stopped on its own context because ctx has been cancelled by Ctrl-C at this point
+	stopCtx, cancel := context.WithTimeout(context.Background(), liveStopTimeout)
+	defer cancel()
+	if err := session.Stop(stopCtx); err != nil {
+		fmt.Fprintf(c.stderr, "failed to stop broadcast %s: %v\n", created.Broadcast.ID, err)
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Broadcast %s is stopped.\n", created.Broadcast.ID)
+	return nil
+}
+
+func (c *cli) prompt(ctx context.Context, in *bufio.Reader, label string) (string, error) {
+	fmt.Fprintf(c.stdout, "%s: ", label)
+
+	type line struct {
+		text string
+		err  error
+	}
+	ch := make(chan line, 1)
+	go func() {
+		text, err := in.ReadString('\n')
+		if err == io.EOF && text != "" {
+			err = nil
+		}
+		ch <- line{strings.TrimSpace(text), err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case l := <-ch:
+		return l.text, l.err
+	}
+}
+
+type spinner struct {
+	done     chan struct{}
+	finished chan struct{}
+}
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+func startSpinner(w io.Writer, message string) *spinner {
+	s := &spinner{
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.finished)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		start := time.Now()
+		for i := 0; ; i++ {
+			fmt.Fprintf(w, "\r%s %s... %s", spinnerFrames[i%len(spinnerFrames)], message, time.Since(start).Truncate(time.Second))
+			select {
+			case <-s.done:
+				fmt.Fprint(w, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *spinner) stop() {
+	close(s.done)
+	<-s.finished
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/openfresh/goperiscope/periscopetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLive(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// plays the encoder, then presses Ctrl-C once the broadcast is live
+	go func() {
+		for ctx.Err() == nil {
+			time.Sleep(5 * time.Millisecond)
+			bs := s.Broadcasts()
+			if len(bs) == 0 {
+				continue
+			}
+			s.SetStreamActive(bs[0].ID, true)
+			if bs[0].State == goperiscope.BroadcastStateRunning {
+				cancel()
+			}
+		}
+	}()
+
+	c, stdout, _ := newTestCLI(serverEnv(s))
+	c.stdin = strings.NewReader("My broadcast\nja\n")
+	assert.Equal(t, exitOK, c.run(ctx, []string{"live", "-poll", "10ms"}))
+
+	bs := s.Broadcasts()
+	if assert.Len(t, bs, 1) {
+		assert.Equal(t, goperiscope.BroadcastStateEnded, bs[0].State)
+		assert.Equal(t, "My broadcast", bs[0].Title)
+		assert.Contains(t, stdout.String(), "key_"+bs[0].ID)
+		assert.Contains(t, stdout.String(), "Broadcast "+bs[0].ID+" is stopped.")
+	}
+	assert.Contains(t, string(s.Requests()[len(s.Requests())-2].Body), "ja")
+}
+
+func TestLiveEncoderTimeout(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+
+	c, _, stderr := newTestCLI(serverEnv(s))
+	assert.Equal(t, exitError, c.run(context.Background(), []string{"live", "-title", "t", "-timeout", "50ms", "-poll", "10ms"}))
+	assert.Contains(t, stderr.String(), goperiscope.ErrEncoderTimeout.Error())
+	assert.Empty(t, s.Broadcasts())
+}
@@ -0,0 +1,199 @@
+// Command periscope manages Periscope broadcasts from the shell.
+//
+// Credentials are read from ~/.periscope.json (or the file in PERISCOPE_CONFIG / --config)
+// and from the PERISCOPE_URL_BASE, PERISCOPE_CLIENT_ID, PERISCOPE_CLIENT_SECRET,
+// PERISCOPE_REFRESH_TOKEN and PERISCOPE_TOKEN_FILE environment variables.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/pkg/errors"
+)
+
+// Exit codes. API errors are mapped from Error.StatusCode.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitAuth        = 3
+	exitNotFound    = 4
+	exitConflict    = 5
+	exitRateLimited = 6
+	exitServer      = 7
+)
+
+type usageError struct {
+	message string
+}
+
+func (e usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"region":   {"region", runRegion},
+	"create":   {"create [-region REGION] [-360] [-low-latency]", runCreate},
+	"publish":  {"publish [-title TITLE] [-locale LOCALE] [-tweet] [-super-hearts] BROADCAST_ID", runPublish},
+	"stop":     {"stop BROADCAST_ID", runStop},
+	"get":      {"get BROADCAST_ID", runGet},
+	"live":     {"live [-region REGION] [-title TITLE] [-locale LOCALE] [-profile FILE] [-probe] [-timeout DURATION]", runLive},
+	"delete":   {"delete BROADCAST_ID", runDelete},
+	"token":    {"token refresh", runToken},
+	"validate": {"validate -profile FILE CREATE_RESPONSE_FILE", runValidate},
+}
+
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	json   bool
+	config *config
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		// a second Ctrl-C terminates the process while the broadcast is being cleaned up
+		signal.Stop(sig)
+		cancel()
+	}()
+
+	c := &cli{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}
+	os.Exit(c.run(ctx, os.Args[1:]))
+}
+
+func (c *cli) run(ctx context.Context, args []string) int {
+	fs := c.flagSet("periscope")
+	configPath := fs.String("config", "", "config file (default $PERISCOPE_CONFIG or ~/.periscope.json)")
+	fs.Usage = c.usage
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		c.usage()
+		return exitUsage
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(c.stderr, "periscope: unknown command %q\n", fs.Arg(0))
+		c.usage()
+		return exitUsage
+	}
+
+	path, explicit := *configPath, *configPath != ""
+	if !explicit {
+		path = defaultConfigPath(c.getenv)
+	}
+	conf, err := loadConfig(path, explicit, c.getenv)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "periscope: %v\n", err)
+		return exitError
+	}
+	c.config = conf
+
+	if err := cmd.run(ctx, c, fs.Args()[1:]); err != nil {
+		fmt.Fprintf(c.stderr, "periscope %s: %v\n", fs.Arg(0), err)
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(c.stderr, "usage: periscope %s\n", cmd.usage)
+		}
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: periscope [-config FILE] [-json] COMMAND [ARGS]")
+	fmt.Fprintln(c.stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+// flagSet returns a flag set that also accepts -json, so it can be given before or after the command.
+func (c *cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.BoolVar(&c.json, "json", c.json, "print JSON instead of tables")
+	return fs
+}
+
+func (c *cli) parse(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := fs.Parse(args); err != nil {
+		return usageError{err.Error()}
+	}
+	if fs.NArg() != nargs {
+		return usagef("expected %d argument(s), got %d", nargs, fs.NArg())
+	}
+	return nil
+}
+
+func (c *cli) client(ctx context.Context) (goperiscope.Client, error) {
+	b, err := c.config.builder()
+	if err != nil {
+		return nil, err
+	}
+	return b.BuildClientContext(ctx)
+}
+
+func exitCode(err error) int {
+	if _, ok := err.(usageError); ok {
+		return exitUsage
+	}
+
+	var apiErr *goperiscope.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return exitAuth
+		case code == http.StatusNotFound:
+			return exitNotFound
+		case code == http.StatusConflict || code == http.StatusBadRequest || code == http.StatusUnprocessableEntity:
+			return exitConflict
+		case code == http.StatusTooManyRequests:
+			return exitRateLimited
+		case code >= 500:
+			return exitServer
+		}
+		return exitError
+	}
+	if errors.Is(err, goperiscope.ErrInvalidTransition) || errors.Is(err, goperiscope.ErrValidation) {
+		return exitConflict
+	}
+	return exitError
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/openfresh/goperiscope/periscopetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCLI(env map[string]string) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	return &cli{
+		stdin:  strings.NewReader(""),
+		stdout: stdout,
+		stderr: stderr,
+		getenv: func(key string) string { return env[key] },
+	}, stdout, stderr
+}
+
+func serverEnv(s *periscopetest.Server) map[string]string {
+	return map[string]string{
+		"PERISCOPE_URL_BASE":      s.URL,
+		"PERISCOPE_CLIENT_ID":     "client_id",
+		"PERISCOPE_CLIENT_SECRET": "client_secret",
+		"PERISCOPE_REFRESH_TOKEN": s.RefreshToken(),
+	}
+}
+
+func TestCommands(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+	env := serverEnv(s)
+
+	c, stdout, _ := newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"region"}))
+	assert.Contains(t, stdout.String(), periscopetest.DefaultRegion)
+
+	c, stdout, _ = newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"-json", "create", "-low-latency"}))
+	var created goperiscope.CreateBroadcastResponse
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &created))
+	id := created.Broadcast.ID
+	assert.NotEmpty(t, id)
+	assert.NotEmpty(t, created.Encoder.StreamKey)
+
+	s.SetStreamActive(id, true)
+	c, stdout, _ = newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"publish", "-title", "hello", "-json", id}))
+	assert.Contains(t, stdout.String(), `"state": "running"`)
+
+	c, stdout, _ = newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"get", id}))
+	assert.Contains(t, stdout.String(), "hello")
+	assert.Contains(t, stdout.String(), "running")
+
+	c, stdout, _ = newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"stop", id}))
+	assert.Contains(t, stdout.String(), "stopped")
+
+	c, _, stderr := newTestCLI(env)
+	assert.Equal(t, exitConflict, c.run(context.Background(), []string{"stop", id}))
+	assert.Contains(t, stderr.String(), "periscope stop:")
+
+	c, _, _ = newTestCLI(env)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"delete", id}))
+
+	c, _, _ = newTestCLI(env)
+	assert.Equal(t, exitNotFound, c.run(context.Background(), []string{"get", id}))
+}
+
+func TestExitCodes(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+	env := serverEnv(s)
+
+	c, _, stderr := newTestCLI(env)
+	assert.Equal(t, exitUsage, c.run(context.Background(), []string{}))
+	assert.Contains(t, stderr.String(), "usage:")
+
+	c, _, _ = newTestCLI(env)
+	assert.Equal(t, exitUsage, c.run(context.Background(), []string{"unknown"}))
+
+	c, _, stderr = newTestCLI(env)
+	assert.Equal(t, exitUsage, c.run(context.Background(), []string{"get"}))
+	assert.Contains(t, stderr.String(), "usage: periscope get BROADCAST_ID")
+
+	c, _, _ = newTestCLI(map[string]string{"PERISCOPE_URL_BASE": s.URL})
+	assert.Equal(t, exitError, c.run(context.Background(), []string{"region"}))
+
+	s.InjectFault(periscopetest.Fault{Path: "/region", StatusCode: http.StatusForbidden})
+	c, _, _ = newTestCLI(env)
+	assert.Equal(t, exitAuth, c.run(context.Background(), []string{"region"}))
+
+	s.ClearFaults()
+	s.InjectFault(periscopetest.Fault{Path: "/region", StatusCode: http.StatusTooManyRequests})
+	c, _, _ = newTestCLI(env)
+	assert.Equal(t, exitRateLimited, c.run(context.Background(), []string{"region"}))
+}
+
+func TestTokenRefresh(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "periscope")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.json")
+	tokenPath := filepath.Join(dir, "token.json")
+	conf := config{
+		URLBase:      s.URL,
+		ClientID:     "client_id",
+		ClientSecret: "client_secret",
+		RefreshToken: s.RefreshToken(),
+		TokenFile:    tokenPath,
+	}
+	b, _ := json.Marshal(conf)
+	assert.NoError(t, ioutil.WriteFile(configPath, b, 0600))
+
+	c, stdout, _ := newTestCLI(nil)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"-config", configPath, "token", "refresh"}))
+	assert.Contains(t, stdout.String(), s.AccessToken())
+
+	token, err := goperiscope.NewFileTokenStore(tokenPath).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, s.AccessToken(), token.AccessToken)
+	assert.Equal(t, s.RefreshToken(), token.RefreshToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+
+	c, _, _ = newTestCLI(nil)
+	assert.Equal(t, exitError, c.run(context.Background(), []string{"-config", filepath.Join(dir, "missing.json"), "region"}))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+)
+
+// print writes v as JSON with -json, and rows as a two column table otherwise.
+func (c *cli) print(v interface{}, rows [][2]string) error {
+	if c.json {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", r[0], r[1])
+	}
+	return tw.Flush()
+}
+
+func (c *cli) printStatus(broadcastID, status string) error {
+	v := struct {
+		BroadcastID string `json:"broadcast_id"`
+		Status      string `json:"status"`
+	}{broadcastID, status}
+	return c.print(v, [][2]string{{"ID", broadcastID}, {"STATUS", status}})
+}
+
+func broadcastRows(b goperiscope.Broadcast) [][2]string {
+	return [][2]string{
+		{"ID", b.ID},
+		{"STATE", string(b.State)},
+		{"TITLE", b.Title},
+		{"STREAM ACTIVE", strconv.FormatBool(b.IsStreamActive)},
+	}
+}
+
+func encoderRows(e goperiscope.Encoder) [][2]string {
+	rc := e.RecommendedConfiguration
+	return [][2]string{
+		{"RTMP URL", e.RtmpURL},
+		{"RTMPS URL", e.RtmpsURL},
+		{"STREAM KEY", e.StreamKey},
+		{"VIDEO", fmt.Sprintf("%s %dx%d %dfps %dkbps keyframe %ds", rc.VideoCodec, rc.Width, rc.Height, rc.Framerate, rc.VideoBitrate/1000, rc.KeyframeInterval)},
+		{"AUDIO", fmt.Sprintf("%s %dHz %dch %dkbps", rc.AudioCodec, rc.AudioSamplingRate, rc.AudioNumChannels, rc.AudioBitrate/1000)},
+	}
+}
+
+func formatSeconds(s int) string {
+	return (time.Duration(s) * time.Second).String()
+}
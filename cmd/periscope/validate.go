@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/pkg/errors"
+)
+
+func readJSONFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "%s could not be read", path)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Wrapf(err, "%s is broken", path)
+	}
+	return nil
+}
+
+// runValidate checks an encoder profile against the output of `periscope -json create`.
+func runValidate(ctx context.Context, c *cli, args []string) error {
+	fs := c.flagSet("validate")
+	profilePath := fs.String("profile", "", "encoder profile JSON, with the fields of the recommended configuration")
+	if err := c.parse(fs, args, 1); err != nil {
+		return err
+	}
+	if *profilePath == "" {
+		return usagef("-profile is required")
+	}
+
+	var profile goperiscope.StreamConfiguration
+	if err := readJSONFile(*profilePath, &profile); err != nil {
+		return err
+	}
+	var created goperiscope.CreateBroadcastResponse
+	if err := readJSONFile(fs.Arg(0), &created); err != nil {
+		return err
+	}
+
+	issues := created.Encoder.Validate(profile)
+	if c.json {
+		if issues == nil {
+			issues = goperiscope.EncoderIssues{}
+		}
+		if err := c.print(issues, nil); err != nil {
+			return err
+		}
+	} else {
+		c.printIssues(issues)
+	}
+	return issues.Err()
+}
+
+func (c *cli) printIssues(issues goperiscope.EncoderIssues) {
+	for _, i := range issues {
+		fmt.Fprintln(c.stderr, i)
+	}
+}
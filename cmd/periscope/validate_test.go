@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openfresh/goperiscope/periscopetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+
+	s := periscopetest.NewServer()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "periscope")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, stdout, _ := newTestCLI(serverEnv(s))
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"-json", "create"}))
+	createdPath := filepath.Join(dir, "created.json")
+	assert.NoError(t, ioutil.WriteFile(createdPath, stdout.Bytes(), 0600))
+
+	goodPath := filepath.Join(dir, "good.json")
+	assert.NoError(t, ioutil.WriteFile(goodPath, []byte(`{"video_bitrate": 900000, "keyframe_interval": 3}`), 0600))
+	badPath := filepath.Join(dir, "bad.json")
+	assert.NoError(t, ioutil.WriteFile(badPath, []byte(`{"keyframe_interval": 2, "audio_num_channels": 1}`), 0600))
+
+	c, _, stderr := newTestCLI(nil)
+	assert.Equal(t, exitOK, c.run(context.Background(), []string{"validate", "-profile", goodPath, createdPath}))
+	assert.Contains(t, stderr.String(), "warning: video bitrate 900000 exceeds")
+
+	c, stdout, stderr = newTestCLI(nil)
+	assert.Equal(t, exitConflict, c.run(context.Background(), []string{"-json", "validate", "-profile", badPath, createdPath}))
+	assert.Contains(t, stdout.String(), `"code": "keyframe_interval_mismatch"`)
+	assert.Contains(t, stdout.String(), `"code": "channels_mismatch"`)
+
+	c, _, _ = newTestCLI(nil)
+	assert.Equal(t, exitUsage, c.run(context.Background(), []string{"validate", createdPath}))
+
+	// live refuses to wait for an encoder that does not match, and removes the broadcast again
+	c, _, stderr = newTestCLI(serverEnv(s))
+	assert.Equal(t, exitConflict, c.run(context.Background(), []string{"live", "-profile", badPath}))
+	assert.Contains(t, stderr.String(), "error: keyframe interval")
+	assert.Len(t, s.Broadcasts(), 1)
+}
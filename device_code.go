@@ -0,0 +1,110 @@
+package goperiscope
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDeviceCodeDenied  = errors.New("device code authorization is denied")
+	ErrDeviceCodeExpired = errors.New("device code is expired")
+)
+
+// States of DeviceCodeCheckResponse.
+const (
+	DeviceCodeStateIssued     = "issued"
+	DeviceCodeStateAssociated = "associated"
+	DeviceCodeStateExpired    = "expired"
+	DeviceCodeStateDenied     = "denied"
+)
+
+var (
+	// defaultDeviceCodeInterval is used when the API does not tell the polling interval.
+	defaultDeviceCodeInterval = 5 * time.Second
+	// maxDeviceCodeInterval caps the backoff after transient errors.
+	maxDeviceCodeInterval = 60 * time.Second
+)
+
+func (i AuthClientImpl) RequestDeviceCode() (*DeviceCodeResponse, error) {
+	return i.RequestDeviceCodeContext(context.Background())
+}
+
+func (i AuthClientImpl) RequestDeviceCodeContext(ctx context.Context) (*DeviceCodeResponse, error) {
+	req := DeviceCodeRequest{
+		ClientID: i.clientID,
+	}
+
+	var result DeviceCodeResponse
+	if err := i.request(ctx, "POST", "/device_code/create", req, &result); err != nil {
+		return nil, errors.Wrapf(err, "Periscope /device_code/create is failed")
+	}
+
+	return &result, nil
+}
+
+// PollDeviceToken polls /device_code/check until the user associates deviceCode with their account,
+// denies it, or it expires.
+func (i AuthClientImpl) PollDeviceToken(ctx context.Context, deviceCode *DeviceCodeResponse) (*OAuthRefreshResponse, error) {
+	req := DeviceCodeCheckRequest{
+		DeviceCode: deviceCode.DeviceCode,
+		ClientID:   i.clientID,
+	}
+
+	interval := time.Duration(deviceCode.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceCodeInterval
+	}
+
+	var expired <-chan time.Time
+	if deviceCode.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(deviceCode.ExpiresIn) * time.Second)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-expired:
+			return nil, ErrDeviceCodeExpired
+		case <-time.After(interval):
+		}
+
+		var result DeviceCodeCheckResponse
+		if err := i.request(ctx, "POST", "/device_code/check", req, &result); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if errors.Is(err, ErrServer) || errors.Is(err, ErrRateLimited) || !errors.As(err, new(*Error)) {
+				// transient failure; back off and keep polling
+				interval *= 2
+				if interval > maxDeviceCodeInterval {
+					interval = maxDeviceCodeInterval
+				}
+				continue
+			}
+			return nil, errors.Wrapf(err, "Periscope /device_code/check is failed")
+		}
+
+		switch result.State {
+		case DeviceCodeStateAssociated:
+			return &OAuthRefreshResponse{
+				AccessToken:  result.AccessToken,
+				RefreshToken: result.RefreshToken,
+				User:         result.User,
+				ExpiresIn:    result.ExpiresIn,
+				TokenType:    result.TokenType,
+			}, nil
+		case DeviceCodeStateIssued:
+		case DeviceCodeStateExpired:
+			return nil, ErrDeviceCodeExpired
+		case DeviceCodeStateDenied:
+			return nil, ErrDeviceCodeDenied
+		default:
+			return nil, errors.Errorf("Periscope /device_code/check returned unknown state [state='%s']", result.State)
+		}
+	}
+}
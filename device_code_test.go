@@ -0,0 +1,116 @@
+package goperiscope
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fastDeviceCodePolling() func() {
+	interval, maxInterval := defaultDeviceCodeInterval, maxDeviceCodeInterval
+	defaultDeviceCodeInterval, maxDeviceCodeInterval = time.Millisecond, 10*time.Millisecond
+	return func() {
+		defaultDeviceCodeInterval, maxDeviceCodeInterval = interval, maxInterval
+	}
+}
+
+func TestDeviceCodeFlow(t *testing.T) {
+
+	defer fastDeviceCodePolling()()
+
+	polls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("r.Method = '%s', want '%s'", r.Method, "POST")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/device_code/create":
+			params := DeviceCodeRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "hoge_client_id", params.ClientID)
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"device_code":"hoge_device_code","user_code":"ABCD-1234","associate_url":"https://www.pscp.tv/device","expires_in":300}`))
+		case "/device_code/check":
+			params := DeviceCodeCheckRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "hoge_device_code", params.DeviceCode)
+			assert.Equal(t, "hoge_client_id", params.ClientID)
+
+			polls++
+			switch polls {
+			case 1, 3:
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"state":"issued"}`))
+			case 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"message":"service unavailable"}`))
+			default:
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"state":"associated","access_token":"new_token","refresh_token":"new_refresh_token","user":{"id":"1111"},"expires_in":15551999,"token_type":"Bearer"}`))
+			}
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	c := AuthClientImpl{
+		urlBase:      ts.URL,
+		httpCli:      &http.Client{},
+		useragent:    "goperiscope test",
+		clientID:     "hoge_client_id",
+		clientSecret: "hoge_client_secret",
+	}
+
+	deviceCode, err := c.RequestDeviceCode()
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCD-1234", deviceCode.UserCode)
+	assert.Equal(t, "https://www.pscp.tv/device", deviceCode.AssociateURL)
+
+	result, err := c.PollDeviceToken(context.Background(), deviceCode)
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", result.AccessToken)
+	assert.Equal(t, "new_refresh_token", result.RefreshToken)
+	assert.Equal(t, "1111", result.User.ID)
+	assert.Equal(t, 4, polls)
+}
+
+func TestDeviceCodeDenied(t *testing.T) {
+
+	defer fastDeviceCodePolling()()
+
+	for state, want := range map[string]error{
+		DeviceCodeStateDenied:  ErrDeviceCodeDenied,
+		DeviceCodeStateExpired: ErrDeviceCodeExpired,
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"state":"` + state + `"}`))
+		}))
+
+		c := AuthClientImpl{
+			urlBase:   ts.URL,
+			httpCli:   &http.Client{},
+			useragent: "goperiscope test",
+		}
+
+		result, err := c.PollDeviceToken(context.Background(), &DeviceCodeResponse{DeviceCode: "hoge_device_code"})
+		assert.Nil(t, result)
+		assert.Equal(t, want, err)
+		ts.Close()
+	}
+}
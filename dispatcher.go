@@ -0,0 +1,341 @@
+package goperiscope
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+type BackPressure int
+
+const (
+	// BackPressureBlock makes Dispatch wait while a handler's queue is full.
+	BackPressureBlock BackPressure = iota
+	// BackPressureDrop discards events for a handler whose queue is full.
+	BackPressureDrop
+)
+
+type DispatcherConfig struct {
+	BackPressure BackPressure
+	// BufferSize is the number of events queued per handler and broadcast.
+	BufferSize int
+	// OnPanic is called when a handler panics. The panic is logged when it is nil.
+	OnPanic func(ev Event, recovered interface{})
+}
+
+// Dispatcher fans events out to registered handlers.
+// Each handler runs on its own goroutine per broadcast, so a slow or panicking handler does not affect
+// the others, and every handler sees the events of a broadcast in the order they were dispatched.
+type Dispatcher struct {
+	config  DispatcherConfig
+	dropped uint64
+
+	mu       sync.RWMutex
+	handlers []*eventHandler
+	workers  map[string]map[*eventHandler]*eventQueue
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type eventHandler struct {
+	// eventType is empty for handlers of every event
+	eventType string
+	fn        func(broadcastID string, ev Event)
+}
+
+func (h *eventHandler) accepts(eventType string) bool {
+	return h.eventType == "" || h.eventType == eventType
+}
+
+// eventQueue feeds the worker of a handler.
+// The channel is closed once the queue is closed and no Dispatch is sending to it anymore,
+// so a Dispatch blocked on a full queue never holds the dispatcher's lock.
+type eventQueue struct {
+	events chan Event
+
+	mu      sync.Mutex
+	senders int
+	closed  bool
+}
+
+func (q *eventQueue) acquire() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	q.senders++
+	return true
+}
+
+func (q *eventQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.senders--
+	if q.closed && q.senders == 0 {
+		close(q.events)
+	}
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	if q.senders == 0 {
+		close(q.events)
+	}
+}
+
+func NewDispatcher(config DispatcherConfig) *Dispatcher {
+	if config.BackPressure == BackPressureDrop && config.BufferSize < 1 {
+		config.BufferSize = 1
+	}
+	return &Dispatcher{
+		config:  config,
+		workers: map[string]map[*eventHandler]*eventQueue{},
+	}
+}
+
+func (d *Dispatcher) OnEvent(f func(Event)) {
+	d.on("", f)
+}
+
+// OnBroadcastEvent registers a handler of every event that also receives the broadcast the event belongs to.
+func (d *Dispatcher) OnBroadcastEvent(f func(broadcastID string, ev Event)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, &eventHandler{fn: f})
+}
+
+func (d *Dispatcher) OnChat(f func(ChatMessage)) {
+	d.on(EventTypeChat, func(ev Event) {
+		if m, ok := ev.(ChatMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnHeart(f func(HeartMessage)) {
+	d.on(EventTypeHeart, func(ev Event) {
+		if m, ok := ev.(HeartMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnJoin(f func(JoinMessage)) {
+	d.on(EventTypeJoin, func(ev Event) {
+		if m, ok := ev.(JoinMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnScreenshot(f func(ScreenshotMessage)) {
+	d.on(EventTypeScreenshot, func(ev Event) {
+		if m, ok := ev.(ScreenshotMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnShare(f func(ShareMessage)) {
+	d.on(EventTypeShare, func(ev Event) {
+		if m, ok := ev.(ShareMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnSuperHeart(f func(SuperHeartMessage)) {
+	d.on(EventTypeSuperHeart, func(ev Event) {
+		if m, ok := ev.(SuperHeartMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnViewerCount(f func(ViewerCountMessage)) {
+	d.on(EventTypeViewerCount, func(ev Event) {
+		if m, ok := ev.(ViewerCountMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) OnError(f func(ErrorMessage)) {
+	d.on(EventTypeError, func(ev Event) {
+		if m, ok := ev.(ErrorMessage); ok {
+			f(m)
+		}
+	})
+}
+
+func (d *Dispatcher) on(eventType string, fn func(Event)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, &eventHandler{
+		eventType: eventType,
+		fn:        func(broadcastID string, ev Event) { fn(ev) },
+	})
+}
+
+// Dropped returns how many events were discarded under BackPressureDrop.
+func (d *Dispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// Run dispatches the events of stream until it is closed or ctx is done.
+func (d *Dispatcher) Run(ctx context.Context, stream EventStream) error {
+	broadcastID := stream.BroadcastID()
+	defer d.release(broadcastID)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-stream.Events():
+			if !ok {
+				return nil
+			}
+			d.Dispatch(broadcastID, ev)
+		}
+	}
+}
+
+func (d *Dispatcher) Dispatch(broadcastID string, ev Event) {
+	queues, ok := d.acquireQueues(broadcastID, ev.EventType())
+	if !ok {
+		queues = d.startWorkers(broadcastID, ev.EventType())
+	}
+
+	for _, q := range queues {
+		if d.config.BackPressure == BackPressureDrop {
+			select {
+			case q.events <- ev:
+			default:
+				atomic.AddUint64(&d.dropped, 1)
+			}
+		} else {
+			q.events <- ev
+		}
+		q.done()
+	}
+}
+
+// acquireQueues returns the queues of the handlers accepting eventType, or false when some of them have no worker yet.
+// The caller sends to the returned queues and then calls done on each of them.
+func (d *Dispatcher) acquireQueues(broadcastID string, eventType string) ([]*eventQueue, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.acquireQueuesLocked(broadcastID, eventType)
+}
+
+func (d *Dispatcher) acquireQueuesLocked(broadcastID string, eventType string) ([]*eventQueue, bool) {
+	if d.closed {
+		return nil, true
+	}
+
+	var queues []*eventQueue
+	for _, h := range d.handlers {
+		if !h.accepts(eventType) {
+			continue
+		}
+		q, ok := d.workers[broadcastID][h]
+		if !ok {
+			return nil, false
+		}
+		queues = append(queues, q)
+	}
+
+	acquired := queues[:0]
+	for _, q := range queues {
+		if q.acquire() {
+			acquired = append(acquired, q)
+		}
+	}
+	return acquired, true
+}
+
+// startWorkers starts the missing workers of the handlers accepting eventType and acquires their queues.
+// Both happen under the write lock, so a handler registered meanwhile cannot leave the event without queues.
+func (d *Dispatcher) startWorkers(broadcastID string, eventType string) []*eventQueue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+
+	workers, ok := d.workers[broadcastID]
+	if !ok {
+		workers = map[*eventHandler]*eventQueue{}
+		d.workers[broadcastID] = workers
+	}
+
+	for _, h := range d.handlers {
+		if _, ok := workers[h]; ok || !h.accepts(eventType) {
+			continue
+		}
+		q := &eventQueue{events: make(chan Event, d.config.BufferSize)}
+		workers[h] = q
+		d.wg.Add(1)
+		go d.work(broadcastID, h, q.events)
+	}
+
+	queues, _ := d.acquireQueuesLocked(broadcastID, eventType)
+	return queues
+}
+
+func (d *Dispatcher) work(broadcastID string, h *eventHandler, q <-chan Event) {
+	defer d.wg.Done()
+	for ev := range q {
+		d.handle(broadcastID, h, ev)
+	}
+}
+
+func (d *Dispatcher) handle(broadcastID string, h *eventHandler, ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			if d.config.OnPanic != nil {
+				d.config.OnPanic(ev, r)
+				return
+			}
+			log.Printf("event handler panicked. eventType=%s, panic=%v\n%s", ev.EventType(), r, debug.Stack())
+		}
+	}()
+	h.fn(broadcastID, ev)
+}
+
+// release stops the workers of a finished broadcast once their queues are drained.
+func (d *Dispatcher) release(broadcastID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, q := range d.workers[broadcastID] {
+		q.close()
+	}
+	delete(d.workers, broadcastID)
+}
+
+// Close stops accepting events and waits for the handlers to finish the queued ones.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for broadcastID, workers := range d.workers {
+			for _, q := range workers {
+				q.close()
+			}
+			delete(d.workers, broadcastID)
+		}
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
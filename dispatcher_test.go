@@ -0,0 +1,182 @@
+package goperiscope
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEventStream struct {
+	broadcastID string
+	events      chan Event
+}
+
+func newTestEventStream(broadcastID string, events ...Event) *testEventStream {
+	s := &testEventStream{
+		broadcastID: broadcastID,
+		events:      make(chan Event, len(events)),
+	}
+	for _, ev := range events {
+		s.events <- ev
+	}
+	close(s.events)
+	return s
+}
+
+func (s *testEventStream) BroadcastID() string  { return s.broadcastID }
+func (s *testEventStream) Events() <-chan Event { return s.events }
+func (s *testEventStream) Err() error           { return nil }
+func (s *testEventStream) Close() error         { return nil }
+
+func TestDispatcher(t *testing.T) {
+
+	var mu sync.Mutex
+	var texts []string
+	var viewers []int32
+	var all int
+	var panics int
+
+	d := NewDispatcher(DispatcherConfig{
+		BufferSize: 10,
+		OnPanic: func(ev Event, recovered interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			panics++
+		},
+	})
+
+	d.OnChat(func(m ChatMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		texts = append(texts, m.Text)
+	})
+	d.OnViewerCount(func(m ViewerCountMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		viewers = append(viewers, m.Live)
+	})
+	d.OnEvent(func(ev Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		all++
+	})
+	d.OnHeart(func(m HeartMessage) {
+		panic("boom")
+	})
+
+	stream := newTestEventStream("broadcast_id",
+		ChatMessage{Type: EventTypeChat, Text: "1"},
+		HeartMessage{Type: EventTypeHeart},
+		ViewerCountMessage{Type: EventTypeViewerCount, Live: 10},
+		ChatMessage{Type: EventTypeChat, Text: "2"},
+		HeartMessage{Type: EventTypeHeart},
+		ChatMessage{Type: EventTypeChat, Text: "3"},
+		UnknownEvent{Type: "poll"},
+	)
+
+	assert.NoError(t, d.Run(context.Background(), stream))
+	d.Close()
+
+	assert.Equal(t, []string{"1", "2", "3"}, texts)
+	assert.Equal(t, []int32{10}, viewers)
+	assert.Equal(t, 7, all)
+	assert.Equal(t, 2, panics)
+}
+
+func TestDispatcherDrop(t *testing.T) {
+
+	d := NewDispatcher(DispatcherConfig{BackPressure: BackPressureDrop, BufferSize: 1})
+
+	block := make(chan struct{})
+	handled := 0
+	d.OnChat(func(m ChatMessage) {
+		<-block
+		handled++
+	})
+
+	for i := 0; i < 5; i++ {
+		d.Dispatch("broadcast_id", ChatMessage{Type: EventTypeChat})
+	}
+	close(block)
+	d.Close()
+
+	// one event is being handled and one is queued, at most
+	assert.True(t, handled <= 2)
+	assert.Equal(t, uint64(5-handled), d.Dropped())
+}
+
+func TestDispatcherBlockedSend(t *testing.T) {
+
+	d := NewDispatcher(DispatcherConfig{})
+
+	block := make(chan struct{})
+	d.OnChat(func(m ChatMessage) {
+		<-block
+	})
+
+	// the first event keeps the handler busy and the second one waits for the queue
+	d.Dispatch("broadcast_id", ChatMessage{Type: EventTypeChat})
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		d.Dispatch("broadcast_id", ChatMessage{Type: EventTypeChat})
+	}()
+
+	// registering handlers and dispatching other broadcasts are not blocked by the pending send
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		d.OnJoin(func(m JoinMessage) {})
+		d.Dispatch("other_broadcast_id", JoinMessage{Type: EventTypeJoin})
+	}()
+
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Fatal("dispatcher is locked by a blocked send")
+	}
+
+	close(block)
+	<-sent
+	d.Close()
+}
+
+func TestDispatcherRegisterWhileDispatching(t *testing.T) {
+
+	d := NewDispatcher(DispatcherConfig{BufferSize: 10})
+
+	var mu sync.Mutex
+	handled := 0
+	d.OnChat(func(m ChatMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled++
+	})
+
+	// handlers registered while dispatching make the dispatcher start workers again
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			d.OnChat(func(m ChatMessage) {})
+		}
+	}()
+
+	n := 0
+	for registering := true; registering; n++ {
+		select {
+		case <-done:
+			registering = false
+		default:
+		}
+		d.Dispatch("broadcast_id", ChatMessage{Type: EventTypeChat})
+	}
+	d.Close()
+
+	// no event is lost for the handler registered up front
+	assert.Equal(t, n, handled)
+	assert.Equal(t, uint64(0), d.Dropped())
+}
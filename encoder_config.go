@@ -0,0 +1,193 @@
+package goperiscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// IngestURL returns the RTMP, or with secure the RTMPS, URL including the stream key.
+func (e Encoder) IngestURL(secure bool) string {
+	base := e.RtmpURL
+	if secure {
+		base = e.RtmpsURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + e.StreamKey
+}
+
+func ffmpegVideoCodec(codec string) string {
+	c := strings.ToLower(codec)
+	switch {
+	case strings.Contains(c, "264") || strings.Contains(c, "avc"):
+		return "libx264"
+	case strings.Contains(c, "265") || strings.Contains(c, "hevc"):
+		return "libx265"
+	}
+	return c
+}
+
+func ffmpegAudioCodec(codec string) string {
+	c := strings.ToLower(codec)
+	if strings.Contains(c, "aac") {
+		return "aac"
+	}
+	return c
+}
+
+func kbps(bps uint32) string {
+	return fmt.Sprintf("%dk", bps/1000)
+}
+
+// FFmpegArgs returns the ffmpeg arguments that stream input to the broadcast with the recommended configuration.
+// Settings missing from the recommendation are left to ffmpeg.
+func (r CreateBroadcastResponse) FFmpegArgs(input string, secure bool) []string {
+	c := r.Encoder.RecommendedConfiguration
+	args := []string{"-re", "-i", input}
+
+	if c.VideoCodec != "" {
+		args = append(args, "-c:v", ffmpegVideoCodec(c.VideoCodec), "-pix_fmt", "yuv420p")
+	}
+	if c.VideoBitrate > 0 {
+		args = append(args, "-b:v", kbps(c.VideoBitrate), "-maxrate", kbps(c.VideoBitrate), "-bufsize", kbps(2*c.VideoBitrate))
+	}
+	if c.Framerate > 0 {
+		args = append(args, "-r", strconv.Itoa(int(c.Framerate)))
+		if c.KeyframeInterval > 0 {
+			gop := strconv.Itoa(int(c.Framerate * c.KeyframeInterval))
+			args = append(args, "-g", gop, "-keyint_min", gop)
+		}
+	}
+	if c.Width > 0 && c.Height > 0 {
+		args = append(args, "-s", fmt.Sprintf("%dx%d", c.Width, c.Height))
+	}
+
+	if c.AudioCodec != "" {
+		args = append(args, "-c:a", ffmpegAudioCodec(c.AudioCodec))
+	}
+	if c.AudioBitrate > 0 {
+		args = append(args, "-b:a", kbps(c.AudioBitrate))
+	}
+	if c.AudioSamplingRate > 0 {
+		args = append(args, "-ar", strconv.Itoa(int(c.AudioSamplingRate)))
+	}
+	if c.AudioNumChannels > 0 {
+		args = append(args, "-ac", strconv.Itoa(int(c.AudioNumChannels)))
+	}
+
+	return append(args, "-f", "flv", r.Encoder.IngestURL(secure))
+}
+
+// OBSService is the content of an OBS profile's service.json.
+type OBSService struct {
+	Type     string             `json:"type"`
+	Settings OBSServiceSettings `json:"settings"`
+}
+
+type OBSServiceSettings struct {
+	Server  string `json:"server"`
+	Key     string `json:"key"`
+	UseAuth bool   `json:"use_auth"`
+	BWTest  bool   `json:"bwtest"`
+}
+
+// OBSEncoder is the content of an OBS profile's streamEncoder.json for the x264 encoder.
+type OBSEncoder struct {
+	RateControl string `json:"rate_control"`
+	Bitrate     uint32 `json:"bitrate"`
+	KeyintSec   uint32 `json:"keyint_sec"`
+	Preset      string `json:"preset"`
+}
+
+func (r CreateBroadcastResponse) OBSService(secure bool) OBSService {
+	server := r.Encoder.RtmpURL
+	if secure {
+		server = r.Encoder.RtmpsURL
+	}
+	return OBSService{
+		Type: "rtmp_custom",
+		Settings: OBSServiceSettings{
+			Server: server,
+			Key:    r.Encoder.StreamKey,
+		},
+	}
+}
+
+func (r CreateBroadcastResponse) OBSEncoder() OBSEncoder {
+	c := r.Encoder.RecommendedConfiguration
+	return OBSEncoder{
+		RateControl: "CBR",
+		Bitrate:     c.VideoBitrate / 1000,
+		KeyintSec:   c.KeyframeInterval,
+		Preset:      "veryfast",
+	}
+}
+
+func (r CreateBroadcastResponse) WriteOBSService(w io.Writer, secure bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(r.OBSService(secure))
+}
+
+func (r CreateBroadcastResponse) WriteOBSEncoder(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(r.OBSEncoder())
+}
+
+// WriteOBSProfile writes the output resolution, framerate and bitrates of an OBS profile's basic.ini.
+func (r CreateBroadcastResponse) WriteOBSProfile(w io.Writer) error {
+	c := r.Encoder.RecommendedConfiguration
+	_, err := fmt.Fprintf(w, "[Output]\nMode=Simple\n\n[SimpleOutput]\nVBitrate=%d\nABitrate=%d\n\n[Video]\nBaseCX=%d\nBaseCY=%d\nOutputCX=%d\nOutputCY=%d\nFPSType=1\nFPSInt=%d\n\n[Audio]\nSampleRate=%d\nChannelSetup=%s\n",
+		c.VideoBitrate/1000, c.AudioBitrate/1000, c.Width, c.Height, c.Width, c.Height, c.Framerate, c.AudioSamplingRate, obsChannelSetup(c.AudioNumChannels))
+	return err
+}
+
+func obsChannelSetup(channels uint32) string {
+	if channels == 1 {
+		return "Mono"
+	}
+	return "Stereo"
+}
+
+// Env returns the broadcast and encoder settings as PERISCOPE_* variables, in a stable order.
+func (r CreateBroadcastResponse) Env(secure bool) [][2]string {
+	c := r.Encoder.RecommendedConfiguration
+	u := func(v uint32) string { return strconv.FormatUint(uint64(v), 10) }
+	return [][2]string{
+		{"PERISCOPE_BROADCAST_ID", r.Broadcast.ID},
+		{"PERISCOPE_SHARE_URL", r.ShareURL},
+		{"PERISCOPE_INGEST_URL", r.Encoder.IngestURL(secure)},
+		{"PERISCOPE_RTMP_URL", r.Encoder.RtmpURL},
+		{"PERISCOPE_RTMPS_URL", r.Encoder.RtmpsURL},
+		{"PERISCOPE_STREAM_KEY", r.Encoder.StreamKey},
+		{"PERISCOPE_VIDEO_CODEC", c.VideoCodec},
+		{"PERISCOPE_VIDEO_BITRATE", u(c.VideoBitrate)},
+		{"PERISCOPE_FRAMERATE", u(c.Framerate)},
+		{"PERISCOPE_KEYFRAME_INTERVAL", u(c.KeyframeInterval)},
+		{"PERISCOPE_WIDTH", u(c.Width)},
+		{"PERISCOPE_HEIGHT", u(c.Height)},
+		{"PERISCOPE_AUDIO_CODEC", c.AudioCodec},
+		{"PERISCOPE_AUDIO_SAMPLING_RATE", u(c.AudioSamplingRate)},
+		{"PERISCOPE_AUDIO_BITRATE", u(c.AudioBitrate)},
+		{"PERISCOPE_AUDIO_NUM_CHANNELS", u(c.AudioNumChannels)},
+	}
+}
+
+// WriteEnv writes Env as KEY=value lines that shells and dotenv loaders accept.
+func (r CreateBroadcastResponse) WriteEnv(w io.Writer, secure bool) error {
+	for _, kv := range r.Env(secure) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", kv[0], envQuote(kv[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func envQuote(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\n'\"\\$`#&;|<>()*?!") {
+		return "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+	}
+	return v
+}
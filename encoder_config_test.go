@@ -0,0 +1,92 @@
+package goperiscope
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCreateBroadcastResponse() CreateBroadcastResponse {
+	return CreateBroadcastResponse{
+		Broadcast: Broadcast{ID: "b1"},
+		ShareURL:  "https://www.pscp.tv/w/b1",
+		Encoder: Encoder{
+			StreamKey: "abc123",
+			RtmpURL:   "rtmp://ap-northeast-1.pscp.tv:80/x",
+			RtmpsURL:  "rtmps://ap-northeast-1.pscp.tv:443/x/",
+			RecommendedConfiguration: StreamConfiguration{
+				VideoCodec:        "H.264/AVC",
+				VideoBitrate:      800000,
+				Framerate:         30,
+				KeyframeInterval:  3,
+				Width:             960,
+				Height:            540,
+				AudioCodec:        "AAC",
+				AudioSamplingRate: 44100,
+				AudioBitrate:      96000,
+				AudioNumChannels:  2,
+			},
+		},
+	}
+}
+
+func TestIngestURL(t *testing.T) {
+	e := testCreateBroadcastResponse().Encoder
+	assert.Equal(t, "rtmp://ap-northeast-1.pscp.tv:80/x/abc123", e.IngestURL(false))
+	assert.Equal(t, "rtmps://ap-northeast-1.pscp.tv:443/x/abc123", e.IngestURL(true))
+}
+
+func TestFFmpegArgs(t *testing.T) {
+
+	args := testCreateBroadcastResponse().FFmpegArgs("in.mp4", true)
+	assert.Equal(t, "-re -i in.mp4 -c:v libx264 -pix_fmt yuv420p -b:v 800k -maxrate 800k -bufsize 1600k -r 30 -g 90 -keyint_min 90 -s 960x540 "+
+		"-c:a aac -b:a 96k -ar 44100 -ac 2 -f flv rtmps://ap-northeast-1.pscp.tv:443/x/abc123", strings.Join(args, " "))
+
+	empty := CreateBroadcastResponse{Encoder: Encoder{RtmpURL: "rtmp://host/x", StreamKey: "k"}}
+	assert.Equal(t, []string{"-re", "-i", "in.mp4", "-f", "flv", "rtmp://host/x/k"}, empty.FFmpegArgs("in.mp4", false))
+}
+
+func TestOBSExport(t *testing.T) {
+
+	r := testCreateBroadcastResponse()
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteOBSService(&buf, false))
+	var service map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &service))
+	assert.Equal(t, "rtmp_custom", service["type"])
+	assert.Equal(t, "rtmp://ap-northeast-1.pscp.tv:80/x", service["settings"].(map[string]interface{})["server"])
+	assert.Equal(t, "abc123", service["settings"].(map[string]interface{})["key"])
+
+	buf.Reset()
+	assert.NoError(t, r.WriteOBSEncoder(&buf))
+	assert.JSONEq(t, `{"rate_control":"CBR","bitrate":800,"keyint_sec":3,"preset":"veryfast"}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, r.WriteOBSProfile(&buf))
+	assert.Contains(t, buf.String(), "VBitrate=800\n")
+	assert.Contains(t, buf.String(), "OutputCX=960\nOutputCY=540\n")
+	assert.Contains(t, buf.String(), "FPSInt=30\n")
+	assert.Contains(t, buf.String(), "ChannelSetup=Stereo\n")
+}
+
+func TestWriteEnv(t *testing.T) {
+
+	r := testCreateBroadcastResponse()
+	r.Encoder.RecommendedConfiguration.VideoCodec = "H.264 High"
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteEnv(&buf, false))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 16)
+	assert.Equal(t, "PERISCOPE_BROADCAST_ID=b1", lines[0])
+	assert.Contains(t, lines, "PERISCOPE_INGEST_URL=rtmp://ap-northeast-1.pscp.tv:80/x/abc123")
+	assert.Contains(t, lines, "PERISCOPE_VIDEO_CODEC='H.264 High'")
+	assert.Contains(t, lines, "PERISCOPE_VIDEO_BITRATE=800000")
+
+	assert.Equal(t, `'it'\''s'`, envQuote("it's"))
+	assert.Equal(t, "''", envQuote(""))
+}
@@ -0,0 +1,150 @@
+package goperiscope
+
+import (
+	"fmt"
+	"strings"
+)
+
+type IssueSeverity string
+
+const (
+	IssueWarning IssueSeverity = "warning"
+	IssueError   IssueSeverity = "error"
+)
+
+// Codes of EncoderIssue.
+const (
+	IssueUnsupportedCodec         = "unsupported_codec"
+	IssueBitrateTooHigh           = "bitrate_too_high"
+	IssueKeyframeIntervalMismatch = "keyframe_interval_mismatch"
+	IssueFramerateTooHigh         = "framerate_too_high"
+	IssueResolutionTooHigh        = "resolution_too_high"
+	IssueSampleRateMismatch       = "sample_rate_mismatch"
+	IssueChannelsMismatch         = "channels_mismatch"
+)
+
+// bitrateErrorRatio is how far above the recommended video bitrate a profile may go before it is an error rather than a warning.
+const bitrateErrorRatio = 1.25
+
+type EncoderIssue struct {
+	Severity IssueSeverity `json:"severity"`
+	Code     string        `json:"code"`
+	// Field is the StreamConfiguration JSON field the issue is about.
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (i EncoderIssue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", i.Severity, i.Message, i.Code)
+}
+
+type EncoderIssues []EncoderIssue
+
+func (is EncoderIssues) HasErrors() bool {
+	for _, i := range is {
+		if i.Severity == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+func (is EncoderIssues) filter(severity IssueSeverity) EncoderIssues {
+	var result EncoderIssues
+	for _, i := range is {
+		if i.Severity == severity {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func (is EncoderIssues) Errors() EncoderIssues {
+	return is.filter(IssueError)
+}
+
+func (is EncoderIssues) Warnings() EncoderIssues {
+	return is.filter(IssueWarning)
+}
+
+// Err returns an *EncoderValidationError when there are errors, and nil when there are only warnings.
+func (is EncoderIssues) Err() error {
+	if !is.HasErrors() {
+		return nil
+	}
+	return &EncoderValidationError{Issues: is.Errors()}
+}
+
+// EncoderValidationError matches ErrValidation with errors.Is.
+type EncoderValidationError struct {
+	Issues EncoderIssues
+}
+
+func (e *EncoderValidationError) Error() string {
+	messages := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		messages[i] = issue.Message
+	}
+	return fmt.Sprintf("encoder profile does not match the recommended configuration: %s", strings.Join(messages, "; "))
+}
+
+func (e *EncoderValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ValidateStreamConfiguration compares an encoder profile against the configuration recommended by Periscope.
+// Zero values on either side are treated as unset and not checked.
+func ValidateStreamConfiguration(profile, recommended StreamConfiguration) EncoderIssues {
+	var issues EncoderIssues
+	add := func(severity IssueSeverity, code, field, format string, args ...interface{}) {
+		issues = append(issues, EncoderIssue{
+			Severity: severity,
+			Code:     code,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if profile.VideoCodec != "" && recommended.VideoCodec != "" &&
+		ffmpegVideoCodec(profile.VideoCodec) != ffmpegVideoCodec(recommended.VideoCodec) {
+		add(IssueError, IssueUnsupportedCodec, "video_codec", "video codec %s is not supported, use %s", profile.VideoCodec, recommended.VideoCodec)
+	}
+	if profile.VideoBitrate > 0 && recommended.VideoBitrate > 0 && profile.VideoBitrate > recommended.VideoBitrate {
+		severity := IssueWarning
+		if float64(profile.VideoBitrate) > float64(recommended.VideoBitrate)*bitrateErrorRatio {
+			severity = IssueError
+		}
+		add(severity, IssueBitrateTooHigh, "video_bitrate", "video bitrate %d exceeds the recommended %d", profile.VideoBitrate, recommended.VideoBitrate)
+	}
+	if profile.KeyframeInterval > 0 && recommended.KeyframeInterval > 0 && profile.KeyframeInterval != recommended.KeyframeInterval {
+		add(IssueError, IssueKeyframeIntervalMismatch, "keyframe_interval", "keyframe interval %ds does not match the recommended %ds", profile.KeyframeInterval, recommended.KeyframeInterval)
+	}
+	if profile.Framerate > 0 && recommended.Framerate > 0 && profile.Framerate > recommended.Framerate {
+		add(IssueWarning, IssueFramerateTooHigh, "framerate", "framerate %d exceeds the recommended %d", profile.Framerate, recommended.Framerate)
+	}
+	if profile.Width > 0 && profile.Height > 0 && recommended.Width > 0 && recommended.Height > 0 &&
+		(profile.Width > recommended.Width || profile.Height > recommended.Height) {
+		add(IssueWarning, IssueResolutionTooHigh, "width", "resolution %dx%d exceeds the recommended %dx%d", profile.Width, profile.Height, recommended.Width, recommended.Height)
+	}
+
+	if profile.AudioCodec != "" && recommended.AudioCodec != "" &&
+		ffmpegAudioCodec(profile.AudioCodec) != ffmpegAudioCodec(recommended.AudioCodec) {
+		add(IssueError, IssueUnsupportedCodec, "audio_codec", "audio codec %s is not supported, use %s", profile.AudioCodec, recommended.AudioCodec)
+	}
+	if profile.AudioBitrate > 0 && recommended.AudioBitrate > 0 && profile.AudioBitrate > recommended.AudioBitrate {
+		add(IssueWarning, IssueBitrateTooHigh, "audio_bitrate", "audio bitrate %d exceeds the recommended %d", profile.AudioBitrate, recommended.AudioBitrate)
+	}
+	if profile.AudioSamplingRate > 0 && recommended.AudioSamplingRate > 0 && profile.AudioSamplingRate != recommended.AudioSamplingRate {
+		add(IssueError, IssueSampleRateMismatch, "audio_sampling_rate", "audio sampling rate %dHz does not match the recommended %dHz", profile.AudioSamplingRate, recommended.AudioSamplingRate)
+	}
+	if profile.AudioNumChannels > 0 && recommended.AudioNumChannels > 0 && profile.AudioNumChannels != recommended.AudioNumChannels {
+		add(IssueError, IssueChannelsMismatch, "audio_num_channels", "%d audio channels do not match the recommended %d", profile.AudioNumChannels, recommended.AudioNumChannels)
+	}
+
+	return issues
+}
+
+// Validate checks an encoder profile against the recommended configuration of this encoder.
+func (e Encoder) Validate(profile StreamConfiguration) EncoderIssues {
+	return ValidateStreamConfiguration(profile, e.RecommendedConfiguration)
+}
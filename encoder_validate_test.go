@@ -0,0 +1,59 @@
+package goperiscope
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStreamConfiguration(t *testing.T) {
+
+	recommended := testCreateBroadcastResponse().Encoder.RecommendedConfiguration
+
+	issues := ValidateStreamConfiguration(recommended, recommended)
+	assert.Empty(t, issues)
+	assert.NoError(t, issues.Err())
+
+	// unset fields are not checked
+	assert.Empty(t, ValidateStreamConfiguration(StreamConfiguration{VideoCodec: "libx264", VideoBitrate: 500000}, recommended))
+
+	profile := recommended
+	profile.VideoBitrate = 900000
+	profile.Framerate = 60
+	profile.Width, profile.Height = 1280, 720
+	profile.AudioBitrate = 128000
+	issues = ValidateStreamConfiguration(profile, recommended)
+	assert.Len(t, issues, 4)
+	assert.False(t, issues.HasErrors())
+	assert.Len(t, issues.Warnings(), 4)
+	assert.NoError(t, issues.Err())
+	assert.Equal(t, IssueBitrateTooHigh, issues[0].Code)
+	assert.Equal(t, "video_bitrate", issues[0].Field)
+
+	profile = recommended
+	profile.VideoCodec = "HEVC"
+	profile.VideoBitrate = 2000000
+	profile.KeyframeInterval = 2
+	profile.AudioCodec = "opus"
+	profile.AudioSamplingRate = 48000
+	profile.AudioNumChannels = 1
+	issues = testCreateBroadcastResponse().Encoder.Validate(profile)
+
+	var codes []string
+	for _, i := range issues.Errors() {
+		codes = append(codes, i.Code)
+	}
+	assert.Equal(t, []string{
+		IssueUnsupportedCodec,
+		IssueBitrateTooHigh,
+		IssueKeyframeIntervalMismatch,
+		IssueUnsupportedCodec,
+		IssueSampleRateMismatch,
+		IssueChannelsMismatch,
+	}, codes)
+
+	err := issues.Err()
+	assert.True(t, errors.Is(err, ErrValidation))
+	assert.Contains(t, err.Error(), "keyframe interval 2s does not match the recommended 3s")
+}
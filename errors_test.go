@@ -0,0 +1,125 @@
+package goperiscope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorIs(t *testing.T) {
+
+	cases := []struct {
+		statusCode int
+		message    string
+		want       error
+	}{
+		{http.StatusUnauthorized, "", ErrUnauthorized},
+		{http.StatusForbidden, "", ErrForbidden},
+		{http.StatusNotFound, "", ErrNotFound},
+		{http.StatusTooManyRequests, "", ErrRateLimited},
+		{http.StatusConflict, "Broadcast is already stopped", ErrBroadcastAlreadyStopped},
+		{http.StatusConflict, "Broadcast has already ended", ErrBroadcastAlreadyStopped},
+		{http.StatusBadRequest, "Broadcast is already stopped", ErrBroadcastAlreadyStopped},
+		{http.StatusServiceUnavailable, "Broadcast has already ended", ErrServer},
+		{http.StatusBadRequest, "title is too long", ErrValidation},
+		{http.StatusUnprocessableEntity, "", ErrValidation},
+		{http.StatusBadGateway, "", ErrServer},
+	}
+
+	for _, c := range cases {
+		err := errors.Wrapf(NewError(c.statusCode, nil, internalError{Message: c.message}), "wrapped")
+		assert.True(t, errors.Is(err, c.want), "statusCode=%d", c.statusCode)
+	}
+
+	// other conflicts and server errors are not taken for stopped broadcasts
+	assert.False(t, errors.Is(NewError(http.StatusConflict, nil, internalError{Message: "title is taken"}), ErrBroadcastAlreadyStopped))
+	assert.False(t, errors.Is(NewError(http.StatusInternalServerError, nil, internalError{Message: "broadcast already stopped"}), ErrBroadcastAlreadyStopped))
+}
+
+func TestErrorFromClient(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"Broadcast not found","documentation_url":"https://developer.periscope.tv/"}`))
+	}))
+	defer ts.Close()
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+	}
+
+	_, err := c.GetBroadcast("broadcast_id")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	var apiErr *Error
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "Broadcast not found", apiErr.Message)
+		assert.Equal(t, "https://developer.periscope.tv/", apiErr.DocumentationURL)
+		assert.NotContains(t, apiErr.Error(), "params=")
+		assert.NotContains(t, apiErr.Error(), "%!")
+	}
+
+	authCli := AuthClientImpl{
+		urlBase:   ts.URL,
+		httpCli:   &http.Client{},
+		useragent: "goperiscope test",
+	}
+
+	_, err = authCli.OAuthRefresh("hoge_refresh_token")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Contains(t, err.Error(), "params=")
+}
+
+func TestErrorNonJSONBody(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "hoge_request_id")
+		switch r.URL.Path {
+		case "/region":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html><body>502 Bad Gateway</body></html>" + strings.Repeat(" ", 1024)))
+		}
+	}))
+	defer ts.Close()
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+	}
+
+	// GET with an empty body
+	_, err := c.GetRegion()
+	assert.True(t, errors.Is(err, ErrServer))
+
+	var apiErr *Error
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+		assert.Equal(t, "", apiErr.Body)
+		assert.Equal(t, "hoge_request_id", apiErr.RequestID)
+	}
+
+	// POST with an HTML body
+	err = c.StopBroadcast("broadcast_id")
+	assert.True(t, errors.Is(err, ErrServer))
+	if assert.True(t, errors.As(err, &apiErr)) {
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+		assert.True(t, strings.HasPrefix(apiErr.Body, "<html><body>502 Bad Gateway</body></html>"))
+		assert.Len(t, apiErr.Body, errorBodySnippetSize)
+		assert.Equal(t, "text/html", apiErr.Header.Get("Content-Type"))
+	}
+	assert.Contains(t, err.Error(), `requestID="hoge_request_id"`)
+}
@@ -0,0 +1,110 @@
+package goperiscope
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	EventTypeChat        = "chat"
+	EventTypeHeart       = "heart"
+	EventTypeJoin        = "join"
+	EventTypeScreenshot  = "screenshot"
+	EventTypeShare       = "share"
+	EventTypeSuperHeart  = "super_heart"
+	EventTypeViewerCount = "viewer_count"
+	EventTypeError       = "error"
+)
+
+// Event is a message received on a broadcast's chat stream.
+type Event interface {
+	EventType() string
+}
+
+func (m ChatMessage) EventType() string        { return EventTypeChat }
+func (m HeartMessage) EventType() string       { return EventTypeHeart }
+func (m JoinMessage) EventType() string        { return EventTypeJoin }
+func (m ScreenshotMessage) EventType() string  { return EventTypeScreenshot }
+func (m ShareMessage) EventType() string       { return EventTypeShare }
+func (m SuperHeartMessage) EventType() string  { return EventTypeSuperHeart }
+func (m ViewerCountMessage) EventType() string { return EventTypeViewerCount }
+func (m ErrorMessage) EventType() string       { return EventTypeError }
+
+// UnknownEvent is returned by DecodeEvent for types this package does not know yet.
+type UnknownEvent struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (e UnknownEvent) EventType() string { return e.Type }
+
+var eventDecoders = map[string]func([]byte) (Event, error){
+	EventTypeChat: func(b []byte) (Event, error) {
+		var m ChatMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeHeart: func(b []byte) (Event, error) {
+		var m HeartMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeJoin: func(b []byte) (Event, error) {
+		var m JoinMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeScreenshot: func(b []byte) (Event, error) {
+		var m ScreenshotMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeShare: func(b []byte) (Event, error) {
+		var m ShareMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeSuperHeart: func(b []byte) (Event, error) {
+		var m SuperHeartMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeViewerCount: func(b []byte) (Event, error) {
+		var m ViewerCountMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+	EventTypeError: func(b []byte) (Event, error) {
+		var m ErrorMessage
+		err := json.Unmarshal(b, &m)
+		return m, err
+	},
+}
+
+// DecodeEvent turns a raw chat frame into the message struct matching its type field.
+func DecodeEvent(b []byte) (Event, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, errors.Wrapf(err, "invalid event frame")
+	}
+	return decodeEventAs(envelope.Type, b)
+}
+
+// decodeEventAs decodes b as an event of eventType, whatever its type field says.
+func decodeEventAs(eventType string, b []byte) (Event, error) {
+	decode, ok := eventDecoders[eventType]
+	if !ok {
+		raw := make(json.RawMessage, len(b))
+		copy(raw, b)
+		return UnknownEvent{Type: eventType, Raw: raw}, nil
+	}
+
+	ev, err := decode(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s event", eventType)
+	}
+	return ev, nil
+}
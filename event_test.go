@@ -0,0 +1,54 @@
+package goperiscope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeEvent(t *testing.T) {
+
+	ev, err := DecodeEvent([]byte(`{"id":"1","type":"chat","text":"hello","user":{"id":"1111"},"color":"#ff0000"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, ChatMessage{ID: "1", Type: "chat", Text: "hello", User: User{ID: "1111"}, Color: "#ff0000"}, ev)
+
+	ev, err = DecodeEvent([]byte(`{"type":"super_heart","user":{"id":"2222"},"amount":100,"tier":2}`))
+	assert.NoError(t, err)
+	assert.Equal(t, SuperHeartMessage{Type: "super_heart", User: User{ID: "2222"}, Amount: 100, Tier: 2}, ev)
+
+	ev, err = DecodeEvent([]byte(`{"id":"3","type":"viewer_count","live":10,"total":20}`))
+	assert.NoError(t, err)
+	assert.Equal(t, EventTypeViewerCount, ev.EventType())
+
+	ev, err = DecodeEvent([]byte(`{"id":"4","type":"poll","question":"?"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "poll", ev.EventType())
+	assert.JSONEq(t, `{"id":"4","type":"poll","question":"?"}`, string(ev.(UnknownEvent).Raw))
+
+	_, err = DecodeEvent([]byte(`{"type":"chat","text":1}`))
+	assert.Error(t, err)
+
+	_, err = DecodeEvent([]byte(`[]`))
+	assert.Error(t, err)
+}
+
+func FuzzDecodeEvent(f *testing.F) {
+	f.Add([]byte(`{"id":"1","type":"chat","text":"hello","user":{"id":"1111"}}`))
+	f.Add([]byte(`{"id":"2","type":"share","service":"twitter","user":{"id":"1111"}}`))
+	f.Add([]byte(`{"type":"super_heart","amount":100,"tier":2}`))
+	f.Add([]byte(`{"id":"3","type":"viewer_count","live":10,"total":20}`))
+	f.Add([]byte(`{"type":null}`))
+	f.Add([]byte(`{"type":"chat","user":[]}`))
+	f.Add([]byte(`"chat"`))
+	f.Add([]byte(``))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ev, err := DecodeEvent(b)
+		if err != nil && ev != nil {
+			t.Errorf("DecodeEvent returned both an event and an error: %v", err)
+		}
+		if err == nil && ev == nil {
+			t.Errorf("DecodeEvent returned neither an event nor an error")
+		}
+	})
+}
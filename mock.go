@@ -0,0 +1,261 @@
+package goperiscope
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// MockCall is a call received by MockClient. Context variants are recorded under the
+// name of the method without the Context suffix, and ctx is not part of Args.
+type MockCall struct {
+	Method string
+	Args   []interface{}
+}
+
+func (c MockCall) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = fmt.Sprintf("%#v", a)
+	}
+	return fmt.Sprintf("%s(%s)", c.Method, strings.Join(args, ", "))
+}
+
+// MockTestingT is the subset of *testing.T used by the MockClient assertions.
+type MockTestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// MockClient is a Client for unit tests. It records every call, answers with the
+// matching Func field when set, and with an empty successful response otherwise.
+type MockClient struct {
+	GetRegionFunc        func(ctx context.Context) (*GetRegionResponse, error)
+	CreateBroadcastFunc  func(ctx context.Context, region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error)
+	PublishBroadcastFunc func(ctx context.Context, broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error)
+	StopBroadcastFunc    func(ctx context.Context, broadcastID string) error
+	GetBroadcastFunc     func(ctx context.Context, broadcastID string) (*Broadcast, error)
+	DeleteBroadcastFunc  func(ctx context.Context, broadcastID string) error
+	ConnectChatFunc      func(ctx context.Context, broadcastID string) (EventStream, error)
+
+	mu     sync.Mutex
+	calls  []MockCall
+	queued map[string][]error
+}
+
+func NewMockClient() *MockClient {
+	return &MockClient{}
+}
+
+// QueueError makes the next calls of method fail with errs, one per call, before the Func field is used again.
+func (m *MockClient) QueueError(method string, errs ...error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.queued == nil {
+		m.queued = map[string][]error{}
+	}
+	m.queued[method] = append(m.queued[method], errs...)
+}
+
+func (m *MockClient) record(method string, args ...interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, MockCall{Method: method, Args: args})
+
+	if errs := m.queued[method]; len(errs) > 0 {
+		m.queued[method] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+func (m *MockClient) Calls() []MockCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]MockCall(nil), m.calls...)
+}
+
+func (m *MockClient) CallsTo(method string) []MockCall {
+	var result []MockCall
+	for _, c := range m.Calls() {
+		if c.Method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// Reset forgets the recorded calls and queued errors. Func fields are kept.
+func (m *MockClient) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.queued = nil
+}
+
+// AssertCalled checks that method was called at least once with args.
+func (m *MockClient) AssertCalled(t MockTestingT, method string, args ...interface{}) bool {
+	calls := m.CallsTo(method)
+	for _, c := range calls {
+		if reflect.DeepEqual(c.Args, args) {
+			return true
+		}
+	}
+	want := MockCall{Method: method, Args: args}
+	if len(calls) == 0 {
+		t.Errorf("expected call %s, but %s was never called", want, method)
+		return false
+	}
+	t.Errorf("expected call %s, got %v", want, calls)
+	return false
+}
+
+func (m *MockClient) AssertNotCalled(t MockTestingT, method string) bool {
+	if calls := m.CallsTo(method); len(calls) > 0 {
+		t.Errorf("expected no call to %s, got %v", method, calls)
+		return false
+	}
+	return true
+}
+
+func (m *MockClient) AssertNumberOfCalls(t MockTestingT, method string, n int) bool {
+	if calls := m.CallsTo(method); len(calls) != n {
+		t.Errorf("expected %d calls to %s, got %d", n, method, len(calls))
+		return false
+	}
+	return true
+}
+
+// AssertCallOrder checks that methods were called in this order. Other calls may come in between.
+func (m *MockClient) AssertCallOrder(t MockTestingT, methods ...string) bool {
+	calls := m.Calls()
+	next := 0
+	for _, c := range calls {
+		if next < len(methods) && c.Method == methods[next] {
+			next++
+		}
+	}
+	if next < len(methods) {
+		t.Errorf("expected calls in order %v, got %v", methods, calls)
+		return false
+	}
+	return true
+}
+
+func (m *MockClient) GetRegion() (*GetRegionResponse, error) {
+	return m.GetRegionContext(context.Background())
+}
+
+func (m *MockClient) GetRegionContext(ctx context.Context) (*GetRegionResponse, error) {
+	if err := m.record("GetRegion"); err != nil {
+		return nil, err
+	}
+	if m.GetRegionFunc != nil {
+		return m.GetRegionFunc(ctx)
+	}
+	return &GetRegionResponse{}, nil
+}
+
+func (m *MockClient) CreateBroadcast(region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error) {
+	return m.CreateBroadcastContext(context.Background(), region, is360, isLowLatency)
+}
+
+func (m *MockClient) CreateBroadcastContext(ctx context.Context, region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error) {
+	if err := m.record("CreateBroadcast", region, is360, isLowLatency); err != nil {
+		return nil, err
+	}
+	if m.CreateBroadcastFunc != nil {
+		return m.CreateBroadcastFunc(ctx, region, is360, isLowLatency)
+	}
+	return &CreateBroadcastResponse{}, nil
+}
+
+func (m *MockClient) PublishBroadcast(broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error) {
+	return m.PublishBroadcastContext(context.Background(), broadcastID, title, withTweet, locale, enableSuperHearts)
+}
+
+func (m *MockClient) PublishBroadcastContext(ctx context.Context, broadcastID string, title string, withTweet bool, locale string, enableSuperHearts bool) (*PublishBroadcastResponse, error) {
+	if err := m.record("PublishBroadcast", broadcastID, title, withTweet, locale, enableSuperHearts); err != nil {
+		return nil, err
+	}
+	if m.PublishBroadcastFunc != nil {
+		return m.PublishBroadcastFunc(ctx, broadcastID, title, withTweet, locale, enableSuperHearts)
+	}
+	return &PublishBroadcastResponse{}, nil
+}
+
+func (m *MockClient) StopBroadcast(broadcastID string) error {
+	return m.StopBroadcastContext(context.Background(), broadcastID)
+}
+
+func (m *MockClient) StopBroadcastContext(ctx context.Context, broadcastID string) error {
+	if err := m.record("StopBroadcast", broadcastID); err != nil {
+		return err
+	}
+	if m.StopBroadcastFunc != nil {
+		return m.StopBroadcastFunc(ctx, broadcastID)
+	}
+	return nil
+}
+
+func (m *MockClient) GetBroadcast(broadcastID string) (*Broadcast, error) {
+	return m.GetBroadcastContext(context.Background(), broadcastID)
+}
+
+func (m *MockClient) GetBroadcastContext(ctx context.Context, broadcastID string) (*Broadcast, error) {
+	if err := m.record("GetBroadcast", broadcastID); err != nil {
+		return nil, err
+	}
+	if m.GetBroadcastFunc != nil {
+		return m.GetBroadcastFunc(ctx, broadcastID)
+	}
+	return &Broadcast{ID: broadcastID}, nil
+}
+
+func (m *MockClient) DeleteBroadcast(broadcastID string) error {
+	return m.DeleteBroadcastContext(context.Background(), broadcastID)
+}
+
+func (m *MockClient) DeleteBroadcastContext(ctx context.Context, broadcastID string) error {
+	if err := m.record("DeleteBroadcast", broadcastID); err != nil {
+		return err
+	}
+	if m.DeleteBroadcastFunc != nil {
+		return m.DeleteBroadcastFunc(ctx, broadcastID)
+	}
+	return nil
+}
+
+func (m *MockClient) ConnectChat(ctx context.Context, broadcastID string) (EventStream, error) {
+	if err := m.record("ConnectChat", broadcastID); err != nil {
+		return nil, err
+	}
+	if m.ConnectChatFunc != nil {
+		return m.ConnectChatFunc(ctx, broadcastID)
+	}
+	return NewMockEventStream(broadcastID), nil
+}
+
+// NewMockEventStream returns a stream that delivers events and then ends.
+func NewMockEventStream(broadcastID string, events ...Event) EventStream {
+	s := &mockEventStream{
+		broadcastID: broadcastID,
+		events:      make(chan Event, len(events)),
+	}
+	for _, ev := range events {
+		s.events <- ev
+	}
+	close(s.events)
+	return s
+}
+
+type mockEventStream struct {
+	broadcastID string
+	events      chan Event
+}
+
+func (s *mockEventStream) BroadcastID() string  { return s.broadcastID }
+func (s *mockEventStream) Events() <-chan Event { return s.events }
+func (s *mockEventStream) Err() error           { return nil }
+func (s *mockEventStream) Close() error         { return nil }
@@ -0,0 +1,77 @@
+package goperiscope
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockClient(t *testing.T) {
+
+	m := NewMockClient()
+	m.CreateBroadcastFunc = func(ctx context.Context, region string, is360 bool, isLowLatency bool) (*CreateBroadcastResponse, error) {
+		return &CreateBroadcastResponse{Broadcast: Broadcast{ID: "b1", State: BroadcastStateNotStarted}}, nil
+	}
+
+	var c Client = m
+	created, err := c.CreateBroadcast("ap-northeast-1", false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "b1", created.Broadcast.ID)
+
+	_, err = c.PublishBroadcastContext(context.Background(), "b1", "title", true, "ja", false)
+	assert.NoError(t, err)
+
+	m.QueueError("StopBroadcast", ErrServer)
+	assert.Equal(t, ErrServer, c.StopBroadcast("b1"))
+	assert.NoError(t, c.StopBroadcast("b1"))
+
+	b, err := c.GetBroadcast("b1")
+	assert.NoError(t, err)
+	assert.Equal(t, "b1", b.ID)
+
+	stream, err := c.ConnectChat(context.Background(), "b1")
+	assert.NoError(t, err)
+	_, ok := <-stream.Events()
+	assert.False(t, ok)
+
+	assert.True(t, m.AssertCalled(t, "CreateBroadcast", "ap-northeast-1", false, true))
+	assert.True(t, m.AssertCalled(t, "PublishBroadcast", "b1", "title", true, "ja", false))
+	assert.True(t, m.AssertNumberOfCalls(t, "StopBroadcast", 2))
+	assert.True(t, m.AssertNotCalled(t, "DeleteBroadcast"))
+	assert.True(t, m.AssertCallOrder(t, "CreateBroadcast", "PublishBroadcast", "StopBroadcast", "ConnectChat"))
+	assert.Equal(t, `StopBroadcast("b1")`, m.CallsTo("StopBroadcast")[0].String())
+
+	m.Reset()
+	assert.Empty(t, m.Calls())
+}
+
+func TestMockClientAssertionFailures(t *testing.T) {
+
+	m := NewMockClient()
+	m.DeleteBroadcastFunc = func(ctx context.Context, broadcastID string) error {
+		return errors.New("boom")
+	}
+	assert.EqualError(t, m.DeleteBroadcast("b1"), "boom")
+	m.StopBroadcast("b1")
+
+	rt := &recordingT{}
+	assert.False(t, m.AssertCalled(rt, "DeleteBroadcast", "b2"))
+	assert.False(t, m.AssertCalled(rt, "GetRegion"))
+	assert.False(t, m.AssertNotCalled(rt, "DeleteBroadcast"))
+	assert.False(t, m.AssertNumberOfCalls(rt, "StopBroadcast", 2))
+	assert.False(t, m.AssertCallOrder(rt, "StopBroadcast", "DeleteBroadcast"))
+	assert.Len(t, rt.errors, 5)
+	assert.Contains(t, rt.errors[0], `DeleteBroadcast("b2")`)
+	assert.Contains(t, rt.errors[1], "never called")
+}
@@ -0,0 +1,311 @@
+package goperiscope
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+type ModerationAction int
+
+const (
+	ModerationAllow ModerationAction = iota
+	ModerationTag
+	ModerationDrop
+)
+
+func (a ModerationAction) String() string {
+	switch a {
+	case ModerationTag:
+		return "tag"
+	case ModerationDrop:
+		return "drop"
+	}
+	return "allow"
+}
+
+// ModerationRule inspects a chat message. Returning ModerationAllow means the rule has no objection.
+type ModerationRule interface {
+	Name() string
+	Check(broadcastID string, m ChatMessage, now time.Time) ModerationAction
+}
+
+type ModerationDecision struct {
+	BroadcastID string
+	Message     ChatMessage
+	Action      ModerationAction
+	// Tags are the names of the rules that objected to the message.
+	Tags []string
+}
+
+type ModeratorConfig struct {
+	Rules []ModerationRule
+	// AllowUsers and DenyUsers hold User.ID or User.TwitterUsername values.
+	// Messages of allowed users skip the rules, those of denied users are dropped.
+	AllowUsers []string
+	DenyUsers  []string
+	// DecisionBuffer is the capacity of the Decisions channel. Decisions are dropped while it is full.
+	DecisionBuffer int
+}
+
+// Moderator applies moderation rules to chat messages and reports every decision on Decisions.
+type Moderator struct {
+	rules     []ModerationRule
+	allow     map[string]bool
+	deny      map[string]bool
+	decisions chan ModerationDecision
+	dropped   uint64
+	now       func() time.Time
+}
+
+func NewModerator(config ModeratorConfig) *Moderator {
+	if config.DecisionBuffer <= 0 {
+		config.DecisionBuffer = 100
+	}
+	return &Moderator{
+		rules:     config.Rules,
+		allow:     userSet(config.AllowUsers),
+		deny:      userSet(config.DenyUsers),
+		decisions: make(chan ModerationDecision, config.DecisionBuffer),
+		now:       time.Now,
+	}
+}
+
+func userSet(users []string) map[string]bool {
+	set := map[string]bool{}
+	for _, u := range users {
+		set[strings.ToLower(u)] = true
+	}
+	return set
+}
+
+func (m *Moderator) Decisions() <-chan ModerationDecision {
+	return m.decisions
+}
+
+// DroppedDecisions returns how many decisions were not delivered because Decisions was full.
+func (m *Moderator) DroppedDecisions() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+func (m *Moderator) Moderate(broadcastID string, msg ChatMessage) ModerationDecision {
+	d := ModerationDecision{
+		BroadcastID: broadcastID,
+		Message:     msg,
+	}
+
+	switch {
+	case inUserSet(m.allow, msg.User):
+	case inUserSet(m.deny, msg.User):
+		d.Action = ModerationDrop
+		d.Tags = []string{"deny_list"}
+	default:
+		now := m.now()
+		for _, rule := range m.rules {
+			action := rule.Check(broadcastID, msg, now)
+			if action == ModerationAllow {
+				continue
+			}
+			d.Tags = append(d.Tags, rule.Name())
+			if action > d.Action {
+				d.Action = action
+			}
+		}
+	}
+
+	select {
+	case m.decisions <- d:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+	}
+	return d
+}
+
+func inUserSet(set map[string]bool, u User) bool {
+	return (u.ID != "" && set[strings.ToLower(u.ID)]) ||
+		(u.TwitterUsername != "" && set[strings.ToLower(u.TwitterUsername)])
+}
+
+// Filter returns a stream with the chat messages that the moderator drops removed.
+// Other events pass through unchanged.
+func (m *Moderator) Filter(stream EventStream) EventStream {
+	f := &moderatedStream{
+		EventStream: stream,
+		events:      make(chan Event),
+		done:        make(chan struct{}),
+	}
+
+	go func() {
+		defer close(f.events)
+		for {
+			var ev Event
+			select {
+			case e, ok := <-stream.Events():
+				if !ok {
+					return
+				}
+				ev = e
+			case <-f.done:
+				return
+			}
+
+			if msg, ok := ev.(ChatMessage); ok && m.Moderate(stream.BroadcastID(), msg).Action == ModerationDrop {
+				continue
+			}
+			select {
+			case f.events <- ev:
+			case <-f.done:
+				return
+			}
+		}
+	}()
+	return f
+}
+
+type moderatedStream struct {
+	EventStream
+	events    chan Event
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *moderatedStream) Events() <-chan Event {
+	return s.events
+}
+
+// Close closes the underlying stream and stops forwarding events that nobody receives anymore.
+func (s *moderatedStream) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return s.EventStream.Close()
+}
+
+// WordListRule objects to messages containing any of Words, ignoring case.
+type WordListRule struct {
+	RuleName string
+	Words    []string
+	Action   ModerationAction
+}
+
+func (r WordListRule) Name() string {
+	return r.RuleName
+}
+
+func (r WordListRule) Check(broadcastID string, m ChatMessage, now time.Time) ModerationAction {
+	words := strings.FieldsFunc(strings.ToLower(m.Text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+	for _, w := range words {
+		for _, banned := range r.Words {
+			if w == strings.ToLower(banned) {
+				return r.Action
+			}
+		}
+	}
+	return ModerationAllow
+}
+
+type RegexpRule struct {
+	RuleName string
+	Pattern  *regexp.Regexp
+	Action   ModerationAction
+}
+
+func (r RegexpRule) Name() string {
+	return r.RuleName
+}
+
+func (r RegexpRule) Check(broadcastID string, m ChatMessage, now time.Time) ModerationAction {
+	if r.Pattern.MatchString(m.Text) {
+		return r.Action
+	}
+	return ModerationAllow
+}
+
+// FloodRule objects once a user sends more than Max messages within Window on a broadcast.
+type FloodRule struct {
+	Max    int
+	Window time.Duration
+	Action ModerationAction
+
+	history userHistory
+}
+
+func (r *FloodRule) Name() string {
+	return "flood"
+}
+
+func (r *FloodRule) Check(broadcastID string, m ChatMessage, now time.Time) ModerationAction {
+	if r.history.count(broadcastID+"\x00"+m.User.ID, now, r.Window) > r.Max {
+		return r.Action
+	}
+	return ModerationAllow
+}
+
+// RepeatRule objects once a user sends the same text more than Max times within Window on a broadcast.
+type RepeatRule struct {
+	Max    int
+	Window time.Duration
+	Action ModerationAction
+
+	history userHistory
+}
+
+func (r *RepeatRule) Name() string {
+	return "repeat"
+}
+
+func (r *RepeatRule) Check(broadcastID string, m ChatMessage, now time.Time) ModerationAction {
+	text := strings.ToLower(strings.TrimSpace(m.Text))
+	if r.history.count(broadcastID+"\x00"+m.User.ID+"\x00"+text, now, r.Window) > r.Max {
+		return r.Action
+	}
+	return ModerationAllow
+}
+
+// userHistory counts recent occurrences per key within a sliding window.
+// Keys without recent occurrences are swept once per window, so that the users who left do not accumulate.
+type userHistory struct {
+	mu        sync.Mutex
+	times     map[string][]time.Time
+	lastSweep time.Time
+}
+
+func (h *userHistory) count(key string, now time.Time, window time.Duration) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.times == nil {
+		h.times = map[string][]time.Time{}
+	}
+	if now.Sub(h.lastSweep) >= window {
+		h.sweep(now, window)
+	}
+
+	recent := append(h.recent(key, now, window), now)
+	h.times[key] = recent
+	return len(recent)
+}
+
+func (h *userHistory) recent(key string, now time.Time, window time.Duration) []time.Time {
+	recent := h.times[key][:0]
+	for _, t := range h.times[key] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+func (h *userHistory) sweep(now time.Time, window time.Duration) {
+	for key := range h.times {
+		if recent := h.recent(key, now, window); len(recent) > 0 {
+			h.times[key] = recent
+		} else {
+			delete(h.times, key)
+		}
+	}
+	h.lastSweep = now
+}
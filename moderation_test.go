@@ -0,0 +1,150 @@
+package goperiscope
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chatFrom(userID, text string) ChatMessage {
+	return ChatMessage{Type: EventTypeChat, Text: text, User: User{ID: userID, TwitterUsername: "tw_" + userID}}
+}
+
+func TestModerator(t *testing.T) {
+
+	m := NewModerator(ModeratorConfig{
+		Rules: []ModerationRule{
+			WordListRule{RuleName: "profanity", Words: []string{"Darn"}, Action: ModerationDrop},
+			RegexpRule{RuleName: "link", Pattern: regexp.MustCompile(`https?://`), Action: ModerationTag},
+		},
+		AllowUsers: []string{"mod"},
+		DenyUsers:  []string{"TW_troll"},
+	})
+
+	d := m.Moderate("b1", chatFrom("u1", "hello"))
+	assert.Equal(t, ModerationAllow, d.Action)
+	assert.Empty(t, d.Tags)
+
+	d = m.Moderate("b1", chatFrom("u1", "oh DARN!"))
+	assert.Equal(t, ModerationDrop, d.Action)
+	assert.Equal(t, []string{"profanity"}, d.Tags)
+
+	d = m.Moderate("b1", chatFrom("u1", "see https://example.com darn"))
+	assert.Equal(t, ModerationDrop, d.Action)
+	assert.Equal(t, []string{"profanity", "link"}, d.Tags)
+
+	d = m.Moderate("b1", chatFrom("u1", "see https://example.com"))
+	assert.Equal(t, ModerationTag, d.Action)
+
+	d = m.Moderate("b1", chatFrom("mod", "darn"))
+	assert.Equal(t, ModerationAllow, d.Action)
+
+	d = m.Moderate("b1", chatFrom("troll", "hello"))
+	assert.Equal(t, ModerationDrop, d.Action)
+	assert.Equal(t, []string{"deny_list"}, d.Tags)
+
+	assert.Len(t, m.Decisions(), 6)
+	first := <-m.Decisions()
+	assert.Equal(t, "b1", first.BroadcastID)
+	assert.Equal(t, "hello", first.Message.Text)
+}
+
+func TestModeratorFloodAndRepeat(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+	m := NewModerator(ModeratorConfig{
+		Rules: []ModerationRule{
+			&FloodRule{Max: 3, Window: 10 * time.Second, Action: ModerationDrop},
+			&RepeatRule{Max: 1, Window: time.Minute, Action: ModerationTag},
+		},
+	})
+	m.now = func() time.Time { return now }
+
+	assert.Equal(t, ModerationAllow, m.Moderate("b1", chatFrom("u1", "a")).Action)
+	assert.Equal(t, ModerationTag, m.Moderate("b1", chatFrom("u1", " A ")).Action)
+	assert.Equal(t, ModerationAllow, m.Moderate("b1", chatFrom("u1", "b")).Action)
+	assert.Equal(t, ModerationDrop, m.Moderate("b1", chatFrom("u1", "c")).Action)
+
+	// other users and broadcasts are counted separately
+	assert.Equal(t, ModerationAllow, m.Moderate("b1", chatFrom("u2", "a")).Action)
+	assert.Equal(t, ModerationAllow, m.Moderate("b2", chatFrom("u1", "a")).Action)
+
+	now = now.Add(11 * time.Second)
+	assert.Equal(t, ModerationAllow, m.Moderate("b1", chatFrom("u1", "d")).Action)
+}
+
+func TestUserHistorySweep(t *testing.T) {
+
+	now := time.Unix(1000, 0)
+	var h userHistory
+
+	assert.Equal(t, 1, h.count("u1", now, 10*time.Second))
+	assert.Equal(t, 1, h.count("u2", now.Add(5*time.Second), 10*time.Second))
+	assert.Len(t, h.times, 2)
+
+	// "u1" has nothing within the window anymore
+	assert.Equal(t, 1, h.count("u3", now.Add(12*time.Second), 10*time.Second))
+	assert.Len(t, h.times, 2)
+	assert.NotContains(t, h.times, "u1")
+
+	assert.Equal(t, 1, h.count("u3", now.Add(30*time.Second), 10*time.Second))
+	assert.Len(t, h.times, 1)
+}
+
+func TestModeratorFilter(t *testing.T) {
+
+	m := NewModerator(ModeratorConfig{
+		Rules:          []ModerationRule{WordListRule{RuleName: "spam", Words: []string{"spam"}, Action: ModerationDrop}},
+		DecisionBuffer: 1,
+	})
+
+	stream := newTestEventStream("b1",
+		chatFrom("u1", "hi"),
+		chatFrom("u1", "spam spam"),
+		HeartMessage{Type: EventTypeHeart},
+		chatFrom("u2", "bye"),
+	)
+	filtered := m.Filter(stream)
+	assert.Equal(t, "b1", filtered.BroadcastID())
+
+	var got []Event
+	for ev := range filtered.Events() {
+		got = append(got, ev)
+	}
+	assert.Len(t, got, 3)
+	assert.Equal(t, "hi", got[0].(ChatMessage).Text)
+	assert.Equal(t, EventTypeHeart, got[1].EventType())
+	assert.Equal(t, "bye", got[2].(ChatMessage).Text)
+
+	assert.Len(t, m.Decisions(), 1)
+	assert.Equal(t, uint64(2), m.DroppedDecisions())
+	assert.NoError(t, filtered.Close())
+}
+
+func TestModeratorFilterClose(t *testing.T) {
+
+	m := NewModerator(ModeratorConfig{})
+
+	// the underlying stream is never closed
+	stream := &testEventStream{broadcastID: "b1", events: make(chan Event, 1)}
+	stream.events <- chatFrom("u1", "hi")
+
+	filtered := m.Filter(stream)
+	assert.NoError(t, filtered.Close())
+	assert.NoError(t, filtered.Close())
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for range filtered.Events() {
+		}
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("filter is still running after Close")
+	}
+}
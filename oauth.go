@@ -0,0 +1,96 @@
+package goperiscope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// NewOAuthState returns a random value for the state parameter of the authorization-code flow.
+func NewOAuthState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OAuthCallbackHandler serves the redirect URI of the authorization-code flow.
+// The first callback carrying the expected state is exchanged for tokens, and Wait returns its result.
+type OAuthCallbackHandler struct {
+	authCli     AuthClient
+	redirectURI string
+	state       string
+
+	once   sync.Once
+	done   chan struct{}
+	result *OAuthRefreshResponse
+	err    error
+}
+
+func NewOAuthCallbackHandler(authCli AuthClient, redirectURI string, state string) *OAuthCallbackHandler {
+	return &OAuthCallbackHandler{
+		authCli:     authCli,
+		redirectURI: redirectURI,
+		state:       state,
+		done:        make(chan struct{}),
+	}
+}
+
+func (h *OAuthCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("state") != h.state {
+		http.Error(w, "state mismatch", http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case <-h.done:
+		http.Error(w, "authorization is already completed", http.StatusGone)
+		return
+	default:
+	}
+
+	if e := q.Get("error"); e != "" {
+		h.complete(nil, fmt.Errorf("authorization is denied [error='%s']", e))
+		http.Error(w, "authorization is denied", http.StatusForbidden)
+		return
+	}
+
+	code := q.Get("code")
+	if code == "" {
+		http.Error(w, "code is missing", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.authCli.OAuthExchangeContext(r.Context(), code, h.redirectURI)
+	h.complete(result, err)
+	if err != nil {
+		http.Error(w, "authorization is failed", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Authorization is completed. You can close this window.")
+}
+
+func (h *OAuthCallbackHandler) Wait(ctx context.Context) (*OAuthRefreshResponse, error) {
+	select {
+	case <-h.done:
+		return h.result, h.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (h *OAuthCallbackHandler) complete(result *OAuthRefreshResponse, err error) {
+	h.once.Do(func() {
+		h.result = result
+		h.err = err
+		close(h.done)
+	})
+}
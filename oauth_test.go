@@ -0,0 +1,81 @@
+package goperiscope
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizeURL(t *testing.T) {
+
+	c := AuthClientImpl{
+		clientID: "hoge_client_id",
+	}
+
+	u, err := url.Parse(c.AuthorizeURL("http://localhost:8080/callback", "hoge_state"))
+	assert.NoError(t, err)
+	assert.Equal(t, "www.pscp.tv", u.Host)
+	assert.Equal(t, "/oauth", u.Path)
+	assert.Equal(t, "hoge_client_id", u.Query().Get("client_id"))
+	assert.Equal(t, "http://localhost:8080/callback", u.Query().Get("redirect_uri"))
+	assert.Equal(t, "hoge_state", u.Query().Get("state"))
+}
+
+func TestOAuthCallbackHandler(t *testing.T) {
+
+	redirectURI := "http://localhost:8080/callback"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		correctPath := "/oauth/token"
+		if r.URL.Path != correctPath {
+			t.Errorf("r.URL.Path ='%v', want '%v'", r.URL.Path, correctPath)
+		}
+
+		params := OAuthExchangeRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "authorization_code", params.GrantType)
+		assert.Equal(t, "hoge_client_id", params.ClientID)
+		assert.Equal(t, "hoge_client_secret", params.ClientSecret)
+		assert.Equal(t, "hoge_code", params.Code)
+		assert.Equal(t, redirectURI, params.RedirectURI)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"access_token":"new_token","refresh_token":"new_refresh_token","expires_in":15551999,"token_type":"Bearer"}`))
+	}))
+	defer ts.Close()
+
+	c := &AuthClientImpl{
+		urlBase:      ts.URL,
+		httpCli:      &http.Client{},
+		useragent:    "goperiscope test",
+		clientID:     "hoge_client_id",
+		clientSecret: "hoge_client_secret",
+	}
+
+	h := NewOAuthCallbackHandler(c, redirectURI, "hoge_state")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=hoge_code&state=wrong_state", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=hoge_code&state=hoge_state", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	result, err := h.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "new_token", result.AccessToken)
+	assert.Equal(t, "new_refresh_token", result.RefreshToken)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/callback?code=hoge_code&state=hoge_state", nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -0,0 +1,465 @@
+// Package periscopetest provides an in-memory fake of the Periscope Producer API for tests.
+package periscopetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+)
+
+const (
+	DefaultRegion       = "ap-northeast-1"
+	DefaultAccessToken  = "test_access_token"
+	DefaultRefreshToken = "test_refresh_token"
+)
+
+// Fault makes the server misbehave for requests to Path, or to every path when Path is empty.
+type Fault struct {
+	Path string
+	// Latency delays the response.
+	Latency time.Duration
+	// StatusCode replies with this status and an error body instead of handling the request.
+	StatusCode int
+	// Malformed replies 200 with a body that is not valid JSON.
+	Malformed bool
+	// Times limits how many requests the fault applies to. 0 means until ClearFaults.
+	Times int
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// Server is a stateful fake Periscope API. Broadcasts are kept in memory and
+// move between states following the same rules as goperiscope.BroadcastState.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	region       string
+	accessToken  string
+	refreshToken string
+	tokenSeq     int
+	broadcastSeq int
+	broadcasts   map[string]*broadcast
+	faults       []*Fault
+	requests     []Request
+}
+
+type broadcast struct {
+	goperiscope.Broadcast
+	encoder goperiscope.Encoder
+}
+
+func NewServer() *Server {
+	s := &Server{
+		region:       DefaultRegion,
+		accessToken:  DefaultAccessToken,
+		refreshToken: DefaultRefreshToken,
+		broadcasts:   map[string]*broadcast{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", s.handleToken)
+	mux.HandleFunc("/region", s.authorized(s.handleRegion))
+	mux.HandleFunc("/broadcast", s.authorized(s.handleGetBroadcast))
+	mux.HandleFunc("/broadcast/create", s.authorized(s.handleCreate))
+	mux.HandleFunc("/broadcast/publish", s.authorized(s.handlePublish))
+	mux.HandleFunc("/broadcast/stop", s.authorized(s.handleStop))
+	mux.HandleFunc("/broadcast/delete", s.authorized(s.handleDelete))
+
+	s.Server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+func (s *Server) SetRegion(region string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.region = region
+}
+
+func (s *Server) AccessToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accessToken
+}
+
+func (s *Server) RefreshToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshToken
+}
+
+// ExpireAccessToken makes the current access token invalid, so that clients must refresh it.
+func (s *Server) ExpireAccessToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenSeq++
+	s.accessToken = fmt.Sprintf("%s_%d", DefaultAccessToken, s.tokenSeq)
+}
+
+// InjectFault adds f. Faults are matched in the order they were injected.
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) Broadcast(broadcastID string) (goperiscope.Broadcast, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.broadcasts[broadcastID]
+	if !ok {
+		return goperiscope.Broadcast{}, false
+	}
+	return b.Broadcast, true
+}
+
+func (s *Server) Broadcasts() []goperiscope.Broadcast {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []goperiscope.Broadcast
+	for i := 1; i <= s.broadcastSeq; i++ {
+		if b, ok := s.broadcasts[broadcastID(i)]; ok {
+			result = append(result, b.Broadcast)
+		}
+	}
+	return result
+}
+
+// SetStreamActive simulates an encoder connecting to or leaving a broadcast.
+func (s *Server) SetStreamActive(broadcastID string, active bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.broadcasts[broadcastID]
+	if !ok {
+		return false
+	}
+	b.IsStreamActive = active
+	b.encoder.IsStreamActive = active
+	return true
+}
+
+// SetState forces a broadcast into state, bypassing the transition rules.
+func (s *Server) SetState(broadcastID string, state goperiscope.BroadcastState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.broadcasts[broadcastID]
+	if !ok {
+		return false
+	}
+	b.State = state
+	return true
+}
+
+func broadcastID(seq int) string {
+	return fmt.Sprintf("broadcast%d", seq)
+}
+
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Body: body})
+		f := s.matchFault(r.URL.Path)
+		s.mu.Unlock()
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case f.StatusCode != 0:
+			writeError(w, f.StatusCode, http.StatusText(f.StatusCode))
+		case f.Malformed:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"broadcast": {`))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// matchFault returns a copy of the first fault for path, consuming one of its Times. s.mu must be held.
+func (s *Server) matchFault(path string) Fault {
+	for i, f := range s.faults {
+		if f.Path != "" && f.Path != path {
+			continue
+		}
+		matched := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return matched
+	}
+	return Fault{}
+}
+
+func (s *Server) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		want := "Bearer " + s.accessToken
+		s.mu.Unlock()
+		if r.Header.Get("Authorization") != want {
+			writeError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{
+		"message":           message,
+		"documentation_url": "https://developer.periscope.tv/",
+	})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s is not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var req struct {
+		GrantType    string `json:"grant_type"`
+		RefreshToken string `json:"refresh_token"`
+		Code         string `json:"code"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case req.GrantType == "refresh_token" && req.RefreshToken == s.refreshToken:
+	case req.GrantType == "authorization_code" && req.Code != "":
+	default:
+		writeError(w, http.StatusUnauthorized, "invalid grant")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, goperiscope.OAuthRefreshResponse{
+		AccessToken:  s.accessToken,
+		RefreshToken: s.refreshToken,
+		User: goperiscope.User{
+			ID:       "test_user",
+			Username: "test_user",
+		},
+		ExpiresIn: 3600,
+		TokenType: "Bearer",
+	})
+}
+
+func (s *Server) handleRegion(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, goperiscope.GetRegionResponse{Region: s.region})
+}
+
+func (s *Server) handleGetBroadcast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.broadcasts[r.URL.Query().Get("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "broadcast not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, b.Broadcast)
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var req goperiscope.CreateBroadcastRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Region == "" {
+		writeError(w, http.StatusBadRequest, "region is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.broadcastSeq++
+	id := broadcastID(s.broadcastSeq)
+	b := &broadcast{
+		Broadcast: goperiscope.Broadcast{
+			ID:    id,
+			State: goperiscope.BroadcastStateNotStarted,
+		},
+		encoder: goperiscope.Encoder{
+			StreamKey:   "key_" + id,
+			RtmpURL:     fmt.Sprintf("rtmp://%s.pscp.tv:80/x", req.Region),
+			RtmpsURL:    fmt.Sprintf("rtmps://%s.pscp.tv:443/x", req.Region),
+			DisplayName: "periscopetest",
+			RecommendedConfiguration: goperiscope.StreamConfiguration{
+				VideoCodec:        "H.264/AVC",
+				VideoBitrate:      800000,
+				Framerate:         30,
+				KeyframeInterval:  3,
+				Width:             960,
+				Height:            540,
+				AudioCodec:        "AAC",
+				AudioSamplingRate: 44100,
+				AudioBitrate:      96000,
+				AudioNumChannels:  2,
+			},
+		},
+	}
+	s.broadcasts[id] = b
+
+	writeJSON(w, http.StatusOK, goperiscope.CreateBroadcastResponse{
+		Broadcast: b.Broadcast,
+		VideoAccess: goperiscope.VideoAccess{
+			HlsURL:      fmt.Sprintf("http://%s.pscp.tv/%s/playlist.m3u8", req.Region, id),
+			HTTPSHlsURL: fmt.Sprintf("https://%s.pscp.tv/%s/playlist.m3u8", req.Region, id),
+		},
+		ShareURL: "https://www.pscp.tv/w/" + id,
+		Encoder:  b.encoder,
+	})
+}
+
+// transition moves a broadcast to next, replying with an error when it does not exist or cannot move. s.mu must be held.
+func (s *Server) transition(w http.ResponseWriter, broadcastID string, next goperiscope.BroadcastState) (*broadcast, bool) {
+	b, ok := s.broadcasts[broadcastID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "broadcast not found")
+		return nil, false
+	}
+	if b.State.IsEnded() && next == goperiscope.BroadcastStateEnded {
+		writeError(w, http.StatusConflict, "broadcast is already stopped")
+		return nil, false
+	}
+	if !b.State.CanTransitionTo(next) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("broadcast cannot move from %s to %s", b.State, next))
+		return nil, false
+	}
+	b.State = next
+	return b, true
+}
+
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var req goperiscope.PublishBroadcastRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.transition(w, req.BroadcastID, goperiscope.BroadcastStateRunning)
+	if !ok {
+		return
+	}
+	b.Title = req.Title
+	writeJSON(w, http.StatusOK, goperiscope.PublishBroadcastResponse{Broadcast: b.Broadcast})
+}
+
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var req goperiscope.StopBroadcastRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.transition(w, req.BroadcastID, goperiscope.BroadcastStateEnded)
+	if !ok {
+		return
+	}
+	b.IsStreamActive = false
+	b.encoder.IsStreamActive = false
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var req goperiscope.DeleteBroadcastRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.broadcasts[req.BroadcastID]; !ok {
+		writeError(w, http.StatusNotFound, "broadcast not found")
+		return
+	}
+	delete(s.broadcasts, req.BroadcastID)
+	writeJSON(w, http.StatusOK, goperiscope.DeleteBroadcastResponse{})
+}
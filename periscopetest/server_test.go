@@ -0,0 +1,125 @@
+package periscopetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openfresh/goperiscope"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T, s *Server) goperiscope.Client {
+	b := goperiscope.NewBuilder(s.URL, "periscopetest", "client_id", "client_secret")
+	b.RefreshToken(s.RefreshToken())
+	c, err := b.BuildClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServerLifecycle(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	c := newTestClient(t, s)
+
+	region, err := c.GetRegion()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRegion, region.Region)
+
+	created, err := c.CreateBroadcast(region.Region, false, true)
+	assert.NoError(t, err)
+	id := created.Broadcast.ID
+	assert.Equal(t, goperiscope.BroadcastStateNotStarted, created.Broadcast.State)
+	assert.NotEmpty(t, created.Encoder.StreamKey)
+
+	assert.True(t, s.SetStreamActive(id, true))
+	b, err := c.GetBroadcast(id)
+	assert.NoError(t, err)
+	assert.True(t, b.IsStreamActive)
+
+	published, err := c.PublishBroadcast(id, "title", false, "ja", false)
+	assert.NoError(t, err)
+	assert.Equal(t, goperiscope.BroadcastStateRunning, published.Broadcast.State)
+	assert.Equal(t, "title", published.Broadcast.Title)
+
+	assert.NoError(t, c.StopBroadcast(id))
+	b2, _ := s.Broadcast(id)
+	assert.Equal(t, goperiscope.BroadcastStateEnded, b2.State)
+
+	assert.NoError(t, c.DeleteBroadcast(id))
+	_, err = c.GetBroadcast(id)
+	assert.True(t, errors.Is(err, goperiscope.ErrNotFound))
+	assert.Empty(t, s.Broadcasts())
+}
+
+func TestServerTransitions(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+
+	// each call uses a new client, so the server rather than the client's state cache rejects it
+	newClient := func() goperiscope.Client {
+		return goperiscope.NewClient(s.URL, &http.Client{}, "periscopetest", s.AccessToken())
+	}
+
+	created, err := newClient().CreateBroadcast(DefaultRegion, false, false)
+	assert.NoError(t, err)
+	id := created.Broadcast.ID
+
+	s.SetState(id, goperiscope.BroadcastStateEnded)
+	_, err = newClient().PublishBroadcast(id, "", false, "", false)
+	assert.True(t, errors.Is(err, goperiscope.ErrValidation))
+
+	err = newClient().StopBroadcast(id)
+	assert.True(t, errors.Is(err, goperiscope.ErrBroadcastAlreadyStopped))
+}
+
+func TestServerAuthorization(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	c := newTestClient(t, s)
+
+	s.ExpireAccessToken()
+	_, err := c.GetRegion()
+	assert.NoError(t, err, "client refreshes the expired token")
+
+	_, err = goperiscope.NewClient(s.URL, &http.Client{}, "periscopetest", "wrong").GetRegion()
+	assert.True(t, errors.Is(err, goperiscope.ErrUnauthorized))
+}
+
+func TestServerFaults(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	c := goperiscope.NewClient(s.URL, &http.Client{}, "periscopetest", s.AccessToken())
+
+	s.InjectFault(Fault{Path: "/region", StatusCode: http.StatusServiceUnavailable, Times: 1})
+	_, err := c.GetRegion()
+	assert.True(t, errors.Is(err, goperiscope.ErrServer))
+	_, err = c.GetRegion()
+	assert.NoError(t, err)
+
+	s.InjectFault(Fault{StatusCode: http.StatusUnauthorized})
+	_, err = c.GetRegion()
+	assert.True(t, errors.Is(err, goperiscope.ErrUnauthorized))
+	s.ClearFaults()
+
+	s.InjectFault(Fault{Path: "/broadcast/create", Malformed: true, Times: 1})
+	_, err = c.CreateBroadcast(DefaultRegion, false, false)
+	assert.Error(t, err)
+
+	s.InjectFault(Fault{Latency: 50 * time.Millisecond, Times: 1})
+	start := time.Now()
+	_, err = c.GetRegion()
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	assert.Len(t, s.Requests(), 5)
+	assert.Equal(t, "/broadcast/create", s.Requests()[3].Path)
+	assert.Contains(t, string(s.Requests()[3].Body), DefaultRegion)
+}
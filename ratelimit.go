@@ -0,0 +1,159 @@
+package goperiscope
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit allows Rate requests per second on average with bursts of up to Burst requests.
+// The zero value means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimiter is a client-side token bucket limiter applied globally and per endpoint path.
+// It also honors the X-RateLimit-Remaining and X-RateLimit-Reset headers returned by the API.
+type RateLimiter struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+}
+
+func NewRateLimiter(global RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		now:       time.Now,
+		endpoints: map[string]*tokenBucket{},
+	}
+	l.global = newTokenBucket(global, l.now())
+	return l
+}
+
+func (l *RateLimiter) EndpointLimit(path string, limit RateLimit) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.endpoints[path] = newTokenBucket(limit, l.now())
+	return l
+}
+
+// Wait blocks until a request to path is allowed or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, path string) error {
+	path = endpointPath(path)
+
+	for {
+		l.mu.Lock()
+		now := l.now()
+		endpoint := l.endpoints[path]
+
+		delay := l.global.delay(now)
+		if endpoint != nil {
+			if d := endpoint.delay(now); d > delay {
+				delay = d
+			}
+		}
+		if delay <= 0 {
+			l.global.take()
+			if endpoint != nil {
+				endpoint.take()
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Update blocks further requests to path until the reset time when the API reports no remaining requests.
+func (l *RateLimiter) Update(path string, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > 0 {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	path = endpointPath(path)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	endpoint := l.endpoints[path]
+	if endpoint == nil {
+		endpoint = newTokenBucket(RateLimit{}, l.now())
+		l.endpoints[path] = endpoint
+	}
+	endpoint.blockedUntil = time.Unix(reset, 0)
+}
+
+type tokenBucket struct {
+	limit        RateLimit
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func newTokenBucket(limit RateLimit, now time.Time) *tokenBucket {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &tokenBucket{
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   now,
+	}
+}
+
+func (b *tokenBucket) unlimited() bool {
+	return b.limit.Rate <= 0
+}
+
+// delay refills the bucket and returns how long to wait for the next token.
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	if b.unlimited() {
+		return 0
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if burst := float64(b.limit.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take() {
+	if !b.unlimited() {
+		b.tokens--
+	}
+}
+
+func endpointPath(path string) string {
+	if i := strings.Index(path, "?"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
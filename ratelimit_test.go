@@ -0,0 +1,69 @@
+package goperiscope
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterEndpoint(t *testing.T) {
+
+	l := NewRateLimiter(RateLimit{}).EndpointLimit("/broadcast/create", RateLimit{Rate: 50, Burst: 2})
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, l.Wait(context.Background(), "/broadcast/create"))
+	}
+	// the burst is free, the remaining two wait 20ms each
+	assert.True(t, time.Since(start) >= 35*time.Millisecond)
+
+	// other endpoints are not limited
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Wait(context.Background(), "/broadcast?id=broadcast_id"))
+	}
+	assert.True(t, time.Since(start) < 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.EndpointLimit("/broadcast/create", RateLimit{Rate: 0.001, Burst: 1})
+	assert.NoError(t, l.Wait(ctx, "/broadcast/create"))
+	assert.Equal(t, context.Canceled, l.Wait(ctx, "/broadcast/create"))
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"region":"ap-northeast-1"}`))
+	}))
+	defer ts.Close()
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+		rateLimiter: NewRateLimiter(RateLimit{}),
+	}
+
+	_, err := c.GetRegion()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = c.GetRegionContext(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+}
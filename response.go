@@ -13,15 +13,71 @@ func (r OAuthRefreshRequest) String() string {
 	return fmt.Sprintf("grant_type=%s,client_id=%s,client_secret=%s,refresh_token=%s", r.GrantType, r.ClientID, r.ClientSecret, r.RefreshToken)
 }
 
+type OAuthExchangeRequest struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+}
+
+func (r OAuthExchangeRequest) String() string {
+	return fmt.Sprintf("grant_type=%s,client_id=%s,client_secret=%s,code=%s,redirect_uri=%s", r.GrantType, r.ClientID, r.ClientSecret, r.Code, r.RedirectURI)
+}
+
+type DeviceCodeRequest struct {
+	ClientID string `json:"client_id"`
+}
+
+func (r DeviceCodeRequest) String() string {
+	return fmt.Sprintf("client_id=%s", r.ClientID)
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode   string `json:"device_code"`
+	UserCode     string `json:"user_code"`
+	AssociateURL string `json:"associate_url"`
+	ExpiresIn    int    `json:"expires_in"`
+	Interval     int    `json:"interval"`
+}
+
+func (r DeviceCodeResponse) String() string {
+	return fmt.Sprintf("device_code=%s,user_code=%s,associate_url=%s,expires_in=%d,interval=%d", r.DeviceCode, r.UserCode, r.AssociateURL, r.ExpiresIn, r.Interval)
+}
+
+type DeviceCodeCheckRequest struct {
+	DeviceCode string `json:"device_code"`
+	ClientID   string `json:"client_id"`
+}
+
+func (r DeviceCodeCheckRequest) String() string {
+	return fmt.Sprintf("device_code=%s,client_id=%s", r.DeviceCode, r.ClientID)
+}
+
+// DeviceCodeCheckResponse carries the token fields once State is "associated".
+type DeviceCodeCheckResponse struct {
+	State        string `json:"state"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	User         User   `json:"user"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+}
+
+func (r DeviceCodeCheckResponse) String() string {
+	return fmt.Sprintf("state=%s,access_token=%s,refresh_token=%s,user=[%s],expires_in=%d,token_type=%s", r.State, r.AccessToken, r.RefreshToken, r.User.String(), r.ExpiresIn, r.TokenType)
+}
+
 type OAuthRefreshResponse struct {
-	AccessToken string `json:"access_token"`
-	User        User   `json:"user"`
-	ExpiresIn   int    `json:"expires_in"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	User         User   `json:"user"`
+	ExpiresIn    int    `json:"expires_in"`
+	TokenType    string `json:"token_type"`
 }
 
 func (r OAuthRefreshResponse) String() string {
-	return fmt.Sprintf("access_token=%s,user=[%s],expires_in=%d,token_type=%s", r.AccessToken, r.User.String(), r.ExpiresIn, r.TokenType)
+	return fmt.Sprintf("access_token=%s,refresh_token=%s,user=[%s],expires_in=%d,token_type=%s", r.AccessToken, r.RefreshToken, r.User.String(), r.ExpiresIn, r.TokenType)
 }
 
 type CreateBroadcastRequest struct {
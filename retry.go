@@ -0,0 +1,111 @@
+package goperiscope
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how ClientImpl retries failed requests.
+// GET requests are retried by default, POST requests only when their path is listed in RetryablePOSTPaths.
+type RetryPolicy struct {
+	MaxAttempts          int
+	BaseDelay            time.Duration
+	MaxDelay             time.Duration
+	Jitter               float64 // fraction of each delay that is randomized, between 0 and 1
+	RetryableStatusCodes []int
+	RetryablePOSTPaths   []string
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.5,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func (p RetryPolicy) allows(method, path string) bool {
+	if method == "GET" {
+		return true
+	}
+
+	for _, retryable := range p.RetryablePOSTPaths {
+		if retryable == endpointPath(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	switch e := err.(type) {
+	case *url.Error:
+		return true
+	case *Error:
+		for _, code := range p.RetryableStatusCodes {
+			if code == e.StatusCode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	d -= d * p.Jitter * rand.Float64()
+
+	delay := time.Duration(d)
+	if e, ok := err.(*Error); ok && e.retryAfter > delay {
+		delay = e.retryAfter
+	}
+	return delay
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header, delay-seconds and HTTP-date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// RetryError is returned when a request under a RetryPolicy finally fails.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v [attempts='%d']", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Cause() error {
+	return e.Err
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
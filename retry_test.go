@@ -0,0 +1,95 @@
+package goperiscope
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRetryPolicy() *RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.BaseDelay = time.Millisecond
+	p.MaxDelay = 5 * time.Millisecond
+	return &p
+}
+
+func TestRetryGetRegion(t *testing.T) {
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"unavailable"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"region":"ap-northeast-1"}`))
+	}))
+	defer ts.Close()
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+		retryPolicy: testRetryPolicy(),
+	}
+
+	result, err := c.GetRegion()
+	assert.NoError(t, err)
+	assert.Equal(t, "ap-northeast-1", result.Region)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetryPOST(t *testing.T) {
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"message":"bad gateway"}`))
+	}))
+	defer ts.Close()
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		accessToken: "test-token",
+		retryPolicy: testRetryPolicy(),
+	}
+
+	// POST is not retried unless opted in
+	_, err := c.CreateBroadcast("ap-northeast-1", false, true)
+	assert.Error(t, err)
+	assert.Equal(t, 1, requests)
+
+	requests = 0
+	c.retryPolicy.RetryablePOSTPaths = []string{"/broadcast/stop"}
+
+	err = c.StopBroadcast("broadcast_id")
+	assert.Equal(t, 3, requests)
+
+	assert.Contains(t, err.Error(), "attempts='3'")
+	apiErr, ok := errors.Cause(err).(*Error)
+	if assert.True(t, ok) {
+		assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+
+	assert.Equal(t, 3*time.Second, parseRetryAfter("3"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	p := RetryPolicy{BaseDelay: time.Millisecond}
+	assert.Equal(t, 2*time.Second, p.delay(1, &Error{StatusCode: http.StatusTooManyRequests, retryAfter: 2 * time.Second}))
+	assert.Equal(t, 4*time.Millisecond, p.delay(3, nil))
+}
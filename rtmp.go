@@ -0,0 +1,348 @@
+package goperiscope
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Just enough of RTMP to handshake and send a connect command: chunk streams and AMF0.
+
+const (
+	rtmpVersion          = 3
+	rtmpHandshakeSize    = 1536
+	rtmpDefaultChunkSize = 128
+	rtmpMaxMessageSize   = 1 << 20
+
+	rtmpMsgSetChunkSize = 1
+	rtmpMsgCommandAMF0  = 20
+)
+
+const (
+	amf0Number      = 0x00
+	amf0Boolean     = 0x01
+	amf0String      = 0x02
+	amf0Object      = 0x03
+	amf0Null        = 0x05
+	amf0Undefined   = 0x06
+	amf0ECMAArray   = 0x08
+	amf0ObjectEnd   = 0x09
+	amf0StrictArray = 0x0a
+	amf0LongString  = 0x0c
+)
+
+type rtmpMessage struct {
+	typeID   byte
+	streamID uint32
+	payload  []byte
+}
+
+// amf0Property keeps object properties in the order they are written.
+type amf0Property struct {
+	key   string
+	value interface{}
+}
+
+func amf0Encode(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(amf0Null)
+	case bool:
+		buf.WriteByte(amf0Boolean)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case float64:
+		buf.WriteByte(amf0Number)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case string:
+		buf.WriteByte(amf0String)
+		amf0WriteUTF8(buf, v)
+	case []amf0Property:
+		buf.WriteByte(amf0Object)
+		for _, p := range v {
+			amf0WriteUTF8(buf, p.key)
+			amf0Encode(buf, p.value)
+		}
+		buf.Write([]byte{0, 0, amf0ObjectEnd})
+	}
+}
+
+func amf0WriteUTF8(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// amf0Decode reads one value. Objects and ECMA arrays become map[string]interface{}.
+func amf0Decode(r *bytes.Reader) (interface{}, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch marker {
+	case amf0Number:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case amf0Boolean:
+		b, err := r.ReadByte()
+		return b != 0, err
+	case amf0String:
+		return amf0ReadUTF8(r)
+	case amf0LongString:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		return amf0ReadBytes(r, int(n))
+	case amf0Null, amf0Undefined:
+		return nil, nil
+	case amf0ECMAArray:
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		return amf0DecodeObject(r)
+	case amf0Object:
+		return amf0DecodeObject(r)
+	case amf0StrictArray:
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		if int(n) > r.Len() {
+			return nil, errors.Errorf("AMF0 array length %d is out of range", n)
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = amf0Decode(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, errors.Errorf("unsupported AMF0 marker 0x%02x", marker)
+}
+
+func amf0DecodeObject(r *bytes.Reader) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	for {
+		key, err := amf0ReadUTF8(r)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if end != amf0ObjectEnd {
+				return nil, errors.Errorf("AMF0 object has an empty key")
+			}
+			return obj, nil
+		}
+		if obj[key], err = amf0Decode(r); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func amf0ReadUTF8(r *bytes.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	return amf0ReadBytes(r, int(n))
+}
+
+func amf0ReadBytes(r *bytes.Reader, n int) (string, error) {
+	if n > r.Len() {
+		return "", io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return string(b), err
+}
+
+// rtmpChunkWriter sends every message on a single chunk stream with type 0 headers.
+type rtmpChunkWriter struct {
+	w         io.Writer
+	chunkSize int
+}
+
+func (cw *rtmpChunkWriter) writeMessage(csid byte, m rtmpMessage) error {
+	var buf bytes.Buffer
+	buf.WriteByte(csid & 0x3f)
+	buf.Write([]byte{0, 0, 0})
+	buf.Write([]byte{byte(len(m.payload) >> 16), byte(len(m.payload) >> 8), byte(len(m.payload))})
+	buf.WriteByte(m.typeID)
+	binary.Write(&buf, binary.LittleEndian, m.streamID)
+
+	for i := 0; i < len(m.payload); i += cw.chunkSize {
+		if i > 0 {
+			buf.WriteByte(0xc0 | csid&0x3f)
+		}
+		end := i + cw.chunkSize
+		if end > len(m.payload) {
+			end = len(m.payload)
+		}
+		buf.Write(m.payload[i:end])
+	}
+
+	_, err := cw.w.Write(buf.Bytes())
+	return err
+}
+
+type rtmpChunkStream struct {
+	timestamp uint32
+	length    uint32
+	typeID    byte
+	streamID  uint32
+	extended  bool
+	payload   []byte
+}
+
+// rtmpChunkReader reassembles messages from interleaved chunk streams.
+type rtmpChunkReader struct {
+	r         *bufio.Reader
+	chunkSize uint32
+	streams   map[uint32]*rtmpChunkStream
+}
+
+func newRTMPChunkReader(r io.Reader) *rtmpChunkReader {
+	return &rtmpChunkReader{
+		r:         bufio.NewReader(r),
+		chunkSize: rtmpDefaultChunkSize,
+		streams:   map[uint32]*rtmpChunkStream{},
+	}
+}
+
+func (cr *rtmpChunkReader) readUint(n int) (uint32, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(cr.r, b[:n]); err != nil {
+		return 0, err
+	}
+	var v uint32
+	for _, x := range b[:n] {
+		v = v<<8 | uint32(x)
+	}
+	return v, nil
+}
+
+// readMessage returns the next complete message. Set Chunk Size messages are applied as they arrive.
+func (cr *rtmpChunkReader) readMessage() (rtmpMessage, error) {
+	for {
+		m, ok, err := cr.readChunk()
+		if err != nil {
+			return rtmpMessage{}, err
+		}
+		if !ok {
+			continue
+		}
+		if m.typeID == rtmpMsgSetChunkSize && len(m.payload) >= 4 {
+			size := binary.BigEndian.Uint32(m.payload) & 0x7fffffff
+			if size == 0 || size > rtmpMaxMessageSize {
+				return rtmpMessage{}, errors.Errorf("invalid RTMP chunk size %d", size)
+			}
+			cr.chunkSize = size
+		}
+		return m, nil
+	}
+}
+
+func (cr *rtmpChunkReader) readChunk() (rtmpMessage, bool, error) {
+	b0, err := cr.r.ReadByte()
+	if err != nil {
+		return rtmpMessage{}, false, err
+	}
+	format := b0 >> 6
+	csid := uint32(b0 & 0x3f)
+	switch csid {
+	case 0:
+		v, err := cr.readUint(1)
+		if err != nil {
+			return rtmpMessage{}, false, err
+		}
+		csid = 64 + v
+	case 1:
+		v, err := cr.readUint(2)
+		if err != nil {
+			return rtmpMessage{}, false, err
+		}
+		csid = 64 + v>>8 + (v&0xff)<<8
+	}
+
+	cs, ok := cr.streams[csid]
+	if !ok {
+		if format != 0 {
+			return rtmpMessage{}, false, errors.Errorf("RTMP chunk stream %d starts without a full header", csid)
+		}
+		cs = &rtmpChunkStream{}
+		cr.streams[csid] = cs
+	}
+
+	var ts uint32
+	if format <= 2 {
+		if ts, err = cr.readUint(3); err != nil {
+			return rtmpMessage{}, false, err
+		}
+	}
+	if format <= 1 {
+		if cs.length, err = cr.readUint(3); err != nil {
+			return rtmpMessage{}, false, err
+		}
+		if cs.length > rtmpMaxMessageSize {
+			return rtmpMessage{}, false, errors.Errorf("RTMP message of %d bytes is too large", cs.length)
+		}
+		typeID, err := cr.readUint(1)
+		if err != nil {
+			return rtmpMessage{}, false, err
+		}
+		cs.typeID = byte(typeID)
+	}
+	if format == 0 {
+		var sid [4]byte
+		if _, err := io.ReadFull(cr.r, sid[:]); err != nil {
+			return rtmpMessage{}, false, err
+		}
+		cs.streamID = binary.LittleEndian.Uint32(sid[:])
+	}
+	if format <= 2 {
+		cs.extended = ts == 0xffffff
+	}
+	if cs.extended {
+		if ts, err = cr.readUint(4); err != nil {
+			return rtmpMessage{}, false, err
+		}
+	}
+	if format == 0 {
+		cs.timestamp = ts
+	} else if format <= 2 && len(cs.payload) == 0 {
+		cs.timestamp += ts
+	}
+
+	n := cs.length - uint32(len(cs.payload))
+	if n > cr.chunkSize {
+		n = cr.chunkSize
+	}
+	chunk := make([]byte, n)
+	if _, err := io.ReadFull(cr.r, chunk); err != nil {
+		return rtmpMessage{}, false, err
+	}
+	cs.payload = append(cs.payload, chunk...)
+
+	if uint32(len(cs.payload)) < cs.length {
+		return rtmpMessage{}, false, nil
+	}
+	m := rtmpMessage{typeID: cs.typeID, streamID: cs.streamID, payload: cs.payload}
+	cs.payload = nil
+	return m, true, nil
+}
@@ -0,0 +1,270 @@
+package goperiscope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrRTMPConnectRejected = errors.New("RTMP connect is rejected")
+
+const defaultRTMPProbeTimeout = 10 * time.Second
+
+// RTMPProbeResult reports how long each step of reaching an ingest server took.
+type RTMPProbeResult struct {
+	URL  string `json:"url"`
+	Addr string `json:"addr"`
+	// Dial includes the TLS handshake for rtmps.
+	Dial      time.Duration `json:"dial"`
+	Handshake time.Duration `json:"handshake"`
+	Connect   time.Duration `json:"connect"`
+	Total     time.Duration `json:"total"`
+	// Code is the status code of the connect response, e.g. NetConnection.Connect.Success.
+	Code string `json:"code"`
+	// HandshakeEchoed reports whether S2 echoed C1. Some servers send other data,
+	// which encoders tolerate, so a mismatch is recorded rather than treated as a failure.
+	HandshakeEchoed bool `json:"handshake_echoed"`
+}
+
+// RTMPProber checks that an RTMP or RTMPS ingest server accepts connections,
+// by performing the RTMP handshake and a connect command without publishing anything.
+type RTMPProber struct {
+	Dialer    *net.Dialer
+	TLSConfig *tls.Config
+	// Timeout applies when ctx has no deadline.
+	Timeout time.Duration
+}
+
+// ProbeRTMP probes rawurl with the default RTMPProber.
+func ProbeRTMP(ctx context.Context, rawurl string) (*RTMPProbeResult, error) {
+	return RTMPProber{}.Probe(ctx, rawurl)
+}
+
+// Probe checks the RTMP, or with secure the RTMPS, ingest URL of the encoder.
+func (e Encoder) Probe(ctx context.Context, secure bool) (*RTMPProbeResult, error) {
+	u := e.RtmpURL
+	if secure {
+		u = e.RtmpsURL
+	}
+	return ProbeRTMP(ctx, u)
+}
+
+type rtmpTarget struct {
+	addr   string
+	host   string
+	secure bool
+	app    string
+	tcURL  string
+}
+
+func parseRTMPURL(rawurl string) (*rtmpTarget, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid RTMP URL %s", rawurl)
+	}
+
+	t := &rtmpTarget{host: u.Hostname()}
+	port := u.Port()
+	switch u.Scheme {
+	case "rtmp":
+		if port == "" {
+			port = "1935"
+		}
+	case "rtmps":
+		t.secure = true
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return nil, errors.Errorf("unsupported RTMP URL scheme %s", u.Scheme)
+	}
+	if t.host == "" {
+		return nil, errors.Errorf("RTMP URL %s has no host", rawurl)
+	}
+
+	t.addr = net.JoinHostPort(t.host, port)
+	t.app = strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	t.tcURL = u.Scheme + "://" + t.addr + "/" + t.app
+	return t, nil
+}
+
+func (p RTMPProber) Probe(ctx context.Context, rawurl string) (*RTMPProbeResult, error) {
+	target, err := parseRTMPURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = defaultRTMPProbeTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result := &RTMPProbeResult{URL: rawurl, Addr: target.addr}
+	start := time.Now()
+
+	conn, err := p.dial(ctx, target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "RTMP dial %s is failed", target.addr)
+	}
+	defer conn.Close()
+	result.Dial = time.Since(start)
+
+	// unblock reads and writes when ctx is cancelled
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	step := time.Now()
+	echoed, err := rtmpHandshake(conn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "RTMP handshake with %s is failed", target.addr)
+	}
+	if !echoed {
+		log.Printf("RTMP server does not echo C1 in S2. addr=%s", target.addr)
+	}
+	result.Handshake = time.Since(step)
+	result.HandshakeEchoed = echoed
+
+	step = time.Now()
+	code, err := rtmpConnect(conn, target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "RTMP connect to %s is failed", target.tcURL)
+	}
+	result.Connect = time.Since(step)
+	result.Code = code
+	result.Total = time.Since(start)
+
+	return result, nil
+}
+
+func (p RTMPProber) dial(ctx context.Context, target *rtmpTarget) (net.Conn, error) {
+	dialer := p.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", target.addr)
+	if err != nil || !target.secure {
+		return conn, err
+	}
+
+	config := &tls.Config{}
+	if p.TLSConfig != nil {
+		config = p.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = target.host
+	}
+	tlsConn := tls.Client(conn, config)
+	if deadline, ok := ctx.Deadline(); ok {
+		tlsConn.SetDeadline(deadline)
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// rtmpHandshake exchanges C0/C1/C2 with S0/S1/S2 and reports whether the server echoed C1.
+func rtmpHandshake(rw io.ReadWriter) (bool, error) {
+	c1 := make([]byte, rtmpHandshakeSize)
+	binary.BigEndian.PutUint32(c1, uint32(time.Now().Unix()))
+	if _, err := rand.Read(c1[8:]); err != nil {
+		return false, err
+	}
+	if _, err := rw.Write(append([]byte{rtmpVersion}, c1...)); err != nil {
+		return false, err
+	}
+
+	s := make([]byte, 1+2*rtmpHandshakeSize)
+	if _, err := io.ReadFull(rw, s); err != nil {
+		return false, err
+	}
+	if s[0] != rtmpVersion {
+		return false, errors.Errorf("unsupported RTMP version %d", s[0])
+	}
+	s1, s2 := s[1:1+rtmpHandshakeSize], s[1+rtmpHandshakeSize:]
+	echoed := bytes.Equal(s2[8:], c1[8:])
+
+	_, err := rw.Write(s1)
+	return echoed, err
+}
+
+// rtmpConnect sends the connect command and waits for its _result or _error, returning the status code.
+func rtmpConnect(rw io.ReadWriter, target *rtmpTarget) (string, error) {
+	var payload bytes.Buffer
+	amf0Encode(&payload, "connect")
+	amf0Encode(&payload, float64(1))
+	amf0Encode(&payload, []amf0Property{
+		{"app", target.app},
+		{"type", "nonprivate"},
+		{"flashVer", "FMLE/3.0 (compatible; goperiscope)"},
+		{"tcUrl", target.tcURL},
+	})
+
+	cw := &rtmpChunkWriter{w: rw, chunkSize: rtmpDefaultChunkSize}
+	if err := cw.writeMessage(3, rtmpMessage{typeID: rtmpMsgCommandAMF0, payload: payload.Bytes()}); err != nil {
+		return "", err
+	}
+
+	cr := newRTMPChunkReader(rw)
+	for {
+		m, err := cr.readMessage()
+		if err != nil {
+			return "", err
+		}
+		if m.typeID != rtmpMsgCommandAMF0 {
+			continue
+		}
+
+		r := bytes.NewReader(m.payload)
+		name, err := amf0Decode(r)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid command message")
+		}
+		txn, err := amf0Decode(r)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid command message")
+		}
+		if txn != float64(1) || (name != "_result" && name != "_error") {
+			continue
+		}
+
+		var code, description string
+		amf0Decode(r) // properties
+		if info, err := amf0Decode(r); err == nil {
+			if obj, ok := info.(map[string]interface{}); ok {
+				code, _ = obj["code"].(string)
+				description, _ = obj["description"].(string)
+			}
+		}
+
+		if name == "_error" {
+			return code, errors.Wrapf(ErrRTMPConnectRejected, "%s %s", code, description)
+		}
+		return code, nil
+	}
+}
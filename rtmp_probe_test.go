@@ -0,0 +1,215 @@
+package goperiscope
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// rtmpStandIn is a minimal RTMP server that answers the handshake and the connect command.
+type rtmpStandIn struct {
+	ln     net.Listener
+	reject bool
+	silent bool
+	noEcho bool
+	tcURLs chan string
+}
+
+func newRTMPStandIn(t *testing.T, ln net.Listener) *rtmpStandIn {
+	s := &rtmpStandIn{ln: ln, tcURLs: make(chan string, 1)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := s.serve(conn); err != nil && err != io.EOF {
+					t.Logf("stand-in RTMP server: %v", err)
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *rtmpStandIn) URL(scheme string) string {
+	return scheme + "://" + s.ln.Addr().String() + "/x"
+}
+
+func (s *rtmpStandIn) serve(conn net.Conn) error {
+	c := make([]byte, 1+rtmpHandshakeSize)
+	if _, err := io.ReadFull(conn, c); err != nil {
+		return err
+	}
+	if s.silent {
+		io.Copy(ioutil.Discard, conn)
+		return nil
+	}
+	s1 := make([]byte, rtmpHandshakeSize)
+	s2 := c[1:]
+	if s.noEcho {
+		s2 = make([]byte, rtmpHandshakeSize)
+	}
+	conn.Write(append(append([]byte{rtmpVersion}, s1...), s2...))
+	if _, err := io.ReadFull(conn, make([]byte, rtmpHandshakeSize)); err != nil {
+		return err
+	}
+
+	m, err := newRTMPChunkReader(conn).readMessage()
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(m.payload)
+	name, _ := amf0Decode(r)
+	txn, _ := amf0Decode(r)
+	obj, _ := amf0Decode(r)
+	if name != "connect" || txn != float64(1) {
+		return errors.Errorf("unexpected command %v %v", name, txn)
+	}
+	s.tcURLs <- obj.(map[string]interface{})["tcUrl"].(string)
+
+	cw := &rtmpChunkWriter{w: conn, chunkSize: rtmpDefaultChunkSize}
+	cw.writeMessage(2, rtmpMessage{typeID: 5, payload: []byte{0, 0x26, 0x25, 0xa0}})
+	chunkSize := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunkSize, 64)
+	cw.writeMessage(2, rtmpMessage{typeID: rtmpMsgSetChunkSize, payload: chunkSize})
+	cw.chunkSize = 64
+
+	var result bytes.Buffer
+	info := []amf0Property{
+		{"level", "status"},
+		{"code", "NetConnection.Connect.Success"},
+		{"description", "Connection succeeded."},
+		{"objectEncoding", float64(0)},
+	}
+	if s.reject {
+		amf0Encode(&result, "_error")
+		info[0].value, info[1].value, info[2].value = "error", "NetConnection.Connect.Rejected", "invalid app"
+	} else {
+		amf0Encode(&result, "_result")
+	}
+	amf0Encode(&result, float64(1))
+	amf0Encode(&result, []amf0Property{{"fmsVer", "FMS/3,0,1,123"}, {"capabilities", float64(31)}})
+	amf0Encode(&result, info)
+	return cw.writeMessage(3, rtmpMessage{typeID: rtmpMsgCommandAMF0, payload: result.Bytes()})
+}
+
+func TestProbeRTMP(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	s := newRTMPStandIn(t, ln)
+
+	result, err := ProbeRTMP(context.Background(), s.URL("rtmp"))
+	assert.NoError(t, err)
+	assert.Equal(t, "NetConnection.Connect.Success", result.Code)
+	assert.Equal(t, ln.Addr().String(), result.Addr)
+	assert.True(t, result.Total >= result.Handshake+result.Connect)
+	assert.Equal(t, s.URL("rtmp"), <-s.tcURLs)
+	assert.True(t, result.HandshakeEchoed)
+
+	// servers that do not echo C1 are still probed to the end
+	s.noEcho = true
+	result, err = ProbeRTMP(context.Background(), s.URL("rtmp"))
+	assert.NoError(t, err)
+	assert.Equal(t, "NetConnection.Connect.Success", result.Code)
+	assert.False(t, result.HandshakeEchoed)
+	<-s.tcURLs
+	s.noEcho = false
+
+	s.reject = true
+	_, err = Encoder{RtmpURL: s.URL("rtmp")}.Probe(context.Background(), false)
+	assert.True(t, errors.Is(err, ErrRTMPConnectRejected))
+	assert.Contains(t, err.Error(), "NetConnection.Connect.Rejected")
+}
+
+func TestProbeRTMPS(t *testing.T) {
+
+	// borrow the test certificate of httptest
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", ts.TLS)
+	assert.NoError(t, err)
+	defer ln.Close()
+	s := newRTMPStandIn(t, ln)
+
+	p := RTMPProber{TLSConfig: ts.Client().Transport.(*http.Transport).TLSClientConfig}
+	result, err := p.Probe(context.Background(), s.URL("rtmps"))
+	assert.NoError(t, err)
+	assert.Equal(t, "NetConnection.Connect.Success", result.Code)
+
+	_, err = ProbeRTMP(context.Background(), s.URL("rtmps"))
+	assert.Error(t, err, "the test certificate is not trusted by default")
+}
+
+func TestProbeRTMPTimeout(t *testing.T) {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	s := newRTMPStandIn(t, ln)
+	s.silent = true
+
+	start := time.Now()
+	_, err = RTMPProber{Timeout: 50 * time.Millisecond}.Probe(context.Background(), s.URL("rtmp"))
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = ProbeRTMP(ctx, s.URL("rtmp"))
+	assert.Error(t, err)
+}
+
+func TestParseRTMPURL(t *testing.T) {
+
+	target, err := parseRTMPURL("rtmp://ap-northeast-1.pscp.tv:80/x/key")
+	assert.NoError(t, err)
+	assert.Equal(t, "ap-northeast-1.pscp.tv:80", target.addr)
+	assert.Equal(t, "x", target.app)
+	assert.Equal(t, "rtmp://ap-northeast-1.pscp.tv:80/x", target.tcURL)
+
+	target, err = parseRTMPURL("rtmps://ap-northeast-1.pscp.tv/x")
+	assert.NoError(t, err)
+	assert.Equal(t, "ap-northeast-1.pscp.tv:443", target.addr)
+	assert.True(t, target.secure)
+
+	_, err = parseRTMPURL("http://example.com/x")
+	assert.Error(t, err)
+}
+
+func TestRTMPChunkReader(t *testing.T) {
+
+	// a type 0 chunk on a 3 byte chunk stream id, continued by a type 3 chunk
+	var buf bytes.Buffer
+	buf.Write([]byte{0x01, 0x00, 0x01})                         // fmt 0, csid 64+0+1*256
+	buf.Write([]byte{0xff, 0xff, 0xff, 0x00, 0x00, 0x82, 0x14}) // extended timestamp, length 130, type 20
+	buf.Write([]byte{0x00, 0x00, 0x00, 0x00})                   // stream id
+	buf.Write([]byte{0x00, 0x01, 0x00, 0x00})                   // extended timestamp
+	buf.Write(bytes.Repeat([]byte{'a'}, 128))
+	buf.Write([]byte{0xc1, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00})
+	buf.Write([]byte{'b', 'c'})
+
+	cr := newRTMPChunkReader(&buf)
+	m, err := cr.readMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(rtmpMsgCommandAMF0), m.typeID)
+	assert.Len(t, m.payload, 130)
+	assert.Equal(t, "bc", string(m.payload[128:]))
+	assert.Equal(t, uint32(0x10000), cr.streams[320].timestamp)
+}
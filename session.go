@@ -0,0 +1,291 @@
+package goperiscope
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type SessionState string
+
+const (
+	SessionIdle          SessionState = "idle"
+	SessionCreated       SessionState = "created"
+	SessionEncoderActive SessionState = "encoder_active"
+	SessionLive          SessionState = "live"
+	SessionStopped       SessionState = "stopped"
+	SessionFailed        SessionState = "failed"
+)
+
+var ErrEncoderTimeout = errors.New("encoder did not connect in time")
+
+const (
+	defaultSessionPollInterval   = 5 * time.Second
+	defaultSessionEncoderTimeout = 5 * time.Minute
+	sessionCleanupTimeout        = 30 * time.Second
+)
+
+type BroadcastSessionConfig struct {
+	// Region is looked up with GetRegion when empty.
+	Region            string
+	Is360             bool
+	IsLowLatency      bool
+	Title             string
+	Locale            string
+	WithTweet         bool
+	EnableSuperHearts bool
+
+	PollInterval   time.Duration
+	EncoderTimeout time.Duration
+	// DeleteOnStop deletes the broadcast after stopping it.
+	DeleteOnStop bool
+	// OnEncoderReady is called with the ingest settings once the broadcast is created, before waiting for the encoder.
+	// An error aborts Start.
+	OnEncoderReady func(*CreateBroadcastResponse) error
+	// BeforePublish is called once the encoder is streaming and returns the title and locale to publish with.
+	// Title and Locale are used when it is nil. An error aborts Start.
+	BeforePublish func(ctx context.Context) (title, locale string, err error)
+	OnStateChange func(SessionState)
+}
+
+// BroadcastSession drives a broadcast from creation to stop:
+// create, wait for the encoder stream, publish, and stop. A failed Start cleans up what it created.
+type BroadcastSession struct {
+	client Client
+	config BroadcastSessionConfig
+	states chan SessionState
+
+	mu        sync.Mutex
+	state     SessionState
+	broadcast *CreateBroadcastResponse
+	// started and stopping are set when Start and Stop begin, so that concurrent calls cannot both proceed
+	started  bool
+	stopping bool
+	// published is set once publishing is attempted, since a failed publish may still have gone live
+	published bool
+	// ended is set once the broadcast is stopped, so that a retried Stop only retries the delete
+	ended bool
+}
+
+func NewBroadcastSession(client Client, config BroadcastSessionConfig) *BroadcastSession {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultSessionPollInterval
+	}
+	if config.EncoderTimeout <= 0 {
+		config.EncoderTimeout = defaultSessionEncoderTimeout
+	}
+
+	return &BroadcastSession{
+		client: client,
+		config: config,
+		// every state is entered at most once, so sends never block
+		states: make(chan SessionState, 6),
+		state:  SessionIdle,
+	}
+}
+
+func (s *BroadcastSession) State() SessionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// States delivers every state transition and is closed once the session is stopped or failed.
+func (s *BroadcastSession) States() <-chan SessionState {
+	return s.states
+}
+
+func (s *BroadcastSession) Broadcast() *CreateBroadcastResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.broadcast
+}
+
+// Start creates the broadcast, waits until the encoder is streaming and publishes it.
+func (s *BroadcastSession) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		state := s.state
+		s.mu.Unlock()
+		return errors.Errorf("session is already started [state='%s']", state)
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	if err := s.start(ctx); err != nil {
+		s.cleanup()
+		s.transition(SessionFailed)
+		return err
+	}
+	return nil
+}
+
+func (s *BroadcastSession) start(ctx context.Context) error {
+	region := s.config.Region
+	if region == "" {
+		r, err := s.client.GetRegionContext(ctx)
+		if err != nil {
+			return err
+		}
+		region = r.Region
+	}
+
+	created, err := s.client.CreateBroadcastContext(ctx, region, s.config.Is360, s.config.IsLowLatency)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.broadcast = created
+	s.mu.Unlock()
+	s.transition(SessionCreated)
+
+	if s.config.OnEncoderReady != nil {
+		if err := s.config.OnEncoderReady(created); err != nil {
+			return err
+		}
+	}
+
+	if err := s.waitEncoder(ctx, created.Broadcast.ID); err != nil {
+		return err
+	}
+	s.transition(SessionEncoderActive)
+
+	title, locale := s.config.Title, s.config.Locale
+	if s.config.BeforePublish != nil {
+		if title, locale, err = s.config.BeforePublish(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.published = true
+	s.mu.Unlock()
+	if _, err := s.client.PublishBroadcastContext(ctx, created.Broadcast.ID, title, s.config.WithTweet, locale, s.config.EnableSuperHearts); err != nil {
+		return err
+	}
+	s.transition(SessionLive)
+
+	return nil
+}
+
+func (s *BroadcastSession) waitEncoder(ctx context.Context, broadcastID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.EncoderTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return ErrEncoderTimeout
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		b, err := s.client.GetBroadcastContext(ctx, broadcastID)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return err
+		}
+		if b.IsStreamActive {
+			return nil
+		}
+	}
+}
+
+// Stop stops a live broadcast, and deletes it when DeleteOnStop is set.
+func (s *BroadcastSession) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.state != SessionLive || s.stopping {
+		state := s.state
+		s.mu.Unlock()
+		return errors.Errorf("session is not live [state='%s']", state)
+	}
+	s.stopping = true
+	id := s.broadcast.Broadcast.ID
+	s.mu.Unlock()
+
+	if err := s.stop(ctx, id); err != nil {
+		// the session is still live, so Stop may be retried
+		s.mu.Lock()
+		s.stopping = false
+		s.mu.Unlock()
+		return err
+	}
+
+	s.transition(SessionStopped)
+	return nil
+}
+
+func (s *BroadcastSession) stop(ctx context.Context, broadcastID string) error {
+	s.mu.Lock()
+	ended := s.ended
+	s.mu.Unlock()
+
+	if !ended {
+		if err := s.client.StopBroadcastContext(ctx, broadcastID); err != nil && !errors.Is(err, ErrBroadcastAlreadyStopped) {
+			return err
+		}
+		s.mu.Lock()
+		s.ended = true
+		s.mu.Unlock()
+	}
+	if s.config.DeleteOnStop {
+		if err := s.client.DeleteBroadcastContext(ctx, broadcastID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanup deletes a broadcast left behind by a failed Start. It runs on its own context because ctx may be the cause of the failure.
+// A broadcast that may have gone live is stopped first.
+func (s *BroadcastSession) cleanup() {
+	s.mu.Lock()
+	b, published := s.broadcast, s.published
+	s.mu.Unlock()
+	if b == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sessionCleanupTimeout)
+	defer cancel()
+
+	// a failed publish may still have reached the API, so the actual state is looked up
+	if published {
+		if current, err := s.client.GetBroadcastContext(ctx, b.Broadcast.ID); err != nil {
+			log.Printf("failed to get broadcast. id=%s, err=%v", b.Broadcast.ID, err)
+		} else if current.State.IsLive() {
+			if err := s.client.StopBroadcastContext(ctx, b.Broadcast.ID); err != nil {
+				log.Printf("failed to stop broadcast. id=%s, err=%v", b.Broadcast.ID, err)
+			}
+		}
+	}
+
+	if err := s.client.DeleteBroadcastContext(ctx, b.Broadcast.ID); err != nil {
+		log.Printf("failed to delete broadcast. id=%s, err=%v", b.Broadcast.ID, err)
+	}
+}
+
+func (s *BroadcastSession) transition(state SessionState) {
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+
+	if s.config.OnStateChange != nil {
+		s.config.OnStateChange(state)
+	}
+
+	s.states <- state
+	if state == SessionStopped || state == SessionFailed {
+		close(s.states)
+	}
+}
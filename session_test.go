@@ -0,0 +1,256 @@
+package goperiscope
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionServer struct {
+	mu            sync.Mutex
+	paths         []string
+	polls         int
+	activeAfter   int
+	createStatus  int
+	publishStatus int
+	published     bool
+	// deleteFailures is how many deletes fail before one succeeds
+	deleteFailures int
+}
+
+func (s *sessionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paths = append(s.paths, r.URL.Path)
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/region":
+		w.Write([]byte(`{"region":"ap-northeast-1"}`))
+	case "/broadcast/create":
+		w.Write([]byte(`{"broadcast":{"id":"broadcast_id","state":"not_started"},"encoder":{"stream_key":"hoge_stream_key"}}`))
+	case "/broadcast":
+		s.polls++
+		if s.published {
+			w.Write([]byte(`{"id":"broadcast_id","state":"running","is_stream_active":true}`))
+			return
+		}
+		if s.activeAfter > 0 && s.polls >= s.activeAfter {
+			w.Write([]byte(`{"id":"broadcast_id","state":"not_started","is_stream_active":true}`))
+			return
+		}
+		w.Write([]byte(`{"id":"broadcast_id","state":"not_started","is_stream_active":false}`))
+	case "/broadcast/publish":
+		// the broadcast goes live even when the response fails
+		s.published = true
+		if s.publishStatus != 0 {
+			w.WriteHeader(s.publishStatus)
+			w.Write([]byte(`{"message":"internal error"}`))
+			return
+		}
+		w.Write([]byte(`{"broadcast":{"id":"broadcast_id","state":"running","title":"title"}}`))
+	case "/broadcast/delete":
+		if s.deleteFailures > 0 {
+			s.deleteFailures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"service unavailable"}`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	default:
+		w.Write([]byte(`{}`))
+	}
+}
+
+func (s *sessionServer) requested() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var paths []string
+	for _, p := range s.paths {
+		if len(paths) == 0 || paths[len(paths)-1] != p {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func TestBroadcastSession(t *testing.T) {
+
+	server := &sessionServer{activeAfter: 3}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	var ready *CreateBroadcastResponse
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Title:          "title",
+		Locale:         "ja_JP",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: time.Second,
+		DeleteOnStop:   true,
+		OnEncoderReady: func(r *CreateBroadcastResponse) error {
+			ready = r
+			return nil
+		},
+	})
+
+	assert.NoError(t, s.Start(context.Background()))
+	assert.Equal(t, SessionLive, s.State())
+	assert.Equal(t, "hoge_stream_key", ready.Encoder.StreamKey)
+
+	assert.NoError(t, s.Stop(context.Background()))
+
+	var states []SessionState
+	for state := range s.States() {
+		states = append(states, state)
+	}
+	assert.Equal(t, []SessionState{SessionCreated, SessionEncoderActive, SessionLive, SessionStopped}, states)
+	assert.Equal(t, []string{"/region", "/broadcast/create", "/broadcast", "/broadcast/publish", "/broadcast/stop", "/broadcast/delete"}, server.requested())
+}
+
+func TestBroadcastSessionEncoderTimeout(t *testing.T) {
+
+	server := &sessionServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Region:         "ap-northeast-1",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: 20 * time.Millisecond,
+	})
+
+	err := s.Start(context.Background())
+	assert.True(t, errors.Is(err, ErrEncoderTimeout))
+	assert.Equal(t, SessionFailed, s.State())
+	assert.Equal(t, []string{"/broadcast/create", "/broadcast", "/broadcast/delete"}, server.requested())
+}
+
+func TestBroadcastSessionPublishFailure(t *testing.T) {
+
+	server := &sessionServer{activeAfter: 1, publishStatus: http.StatusInternalServerError}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Region:         "ap-northeast-1",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: time.Second,
+	})
+
+	assert.Error(t, s.Start(context.Background()))
+	assert.Equal(t, SessionFailed, s.State())
+	assert.Equal(t, []string{"/broadcast/create", "/broadcast", "/broadcast/publish", "/broadcast", "/broadcast/stop", "/broadcast/delete"}, server.requested())
+}
+
+func TestBroadcastSessionConcurrentStop(t *testing.T) {
+
+	server := &sessionServer{activeAfter: 1}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Region:         "ap-northeast-1",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: time.Second,
+	})
+
+	assert.NoError(t, s.Start(context.Background()))
+	assert.Error(t, s.Start(context.Background()))
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Stop(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	// exactly one of them stops the session
+	assert.True(t, (errs[0] == nil) != (errs[1] == nil))
+	assert.Equal(t, SessionStopped, s.State())
+}
+
+func TestBroadcastSessionEncoderReadyError(t *testing.T) {
+
+	server := &sessionServer{activeAfter: 1}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	rejected := errors.New("rejected")
+	published := false
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Region:         "ap-northeast-1",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: time.Second,
+		OnEncoderReady: func(r *CreateBroadcastResponse) error { return rejected },
+		BeforePublish: func(ctx context.Context) (string, string, error) {
+			published = true
+			return "title", "ja_JP", nil
+		},
+	})
+
+	assert.Equal(t, rejected, s.Start(context.Background()))
+	assert.False(t, published)
+	assert.Equal(t, []string{"/broadcast/create", "/broadcast/delete"}, server.requested())
+}
+
+func TestBroadcastSessionStopRetriesDelete(t *testing.T) {
+
+	server := &sessionServer{activeAfter: 1, deleteFailures: 1}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	c := NewClient(ts.URL, &http.Client{}, "goperiscope test", "test-token")
+
+	s := NewBroadcastSession(c, BroadcastSessionConfig{
+		Region:         "ap-northeast-1",
+		PollInterval:   time.Millisecond,
+		EncoderTimeout: time.Second,
+		DeleteOnStop:   true,
+	})
+
+	assert.NoError(t, s.Start(context.Background()))
+
+	err := s.Stop(context.Background())
+	assert.True(t, errors.Is(err, ErrServer))
+	assert.Equal(t, SessionLive, s.State())
+
+	// the broadcast is already stopped, so only the delete is retried
+	assert.NoError(t, s.Stop(context.Background()))
+	assert.Equal(t, SessionStopped, s.State())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	var stops, deletes int
+	for _, p := range server.paths {
+		switch p {
+		case "/broadcast/stop":
+			stops++
+		case "/broadcast/delete":
+			deletes++
+		}
+	}
+	assert.Equal(t, 1, stops)
+	assert.Equal(t, 2, deletes)
+}
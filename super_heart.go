@@ -0,0 +1,176 @@
+package goperiscope
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type SupporterTotal struct {
+	User   User  `json:"user"`
+	Amount int64 `json:"amount"`
+	Count  int   `json:"count"`
+}
+
+type SuperHeartSnapshot struct {
+	BroadcastID string           `json:"broadcast_id"`
+	Amount      int64            `json:"amount"`
+	Count       int              `json:"count"`
+	Tiers       map[int32]int    `json:"tiers"`
+	Supporters  []SupporterTotal `json:"supporters"`
+}
+
+// SuperHeartAggregator tallies super hearts per broadcast and per user.
+type SuperHeartAggregator struct {
+	mu         sync.Mutex
+	broadcasts map[string]*superHeartTally
+	users      map[string]*SupporterTotal
+}
+
+type superHeartTally struct {
+	amount     int64
+	count      int
+	tiers      map[int32]int
+	supporters map[string]*SupporterTotal
+}
+
+func NewSuperHeartAggregator() *SuperHeartAggregator {
+	return &SuperHeartAggregator{
+		broadcasts: map[string]*superHeartTally{},
+		users:      map[string]*SupporterTotal{},
+	}
+}
+
+// Attach feeds the super hearts of every broadcast dispatched by d into the aggregator.
+func (a *SuperHeartAggregator) Attach(d *Dispatcher) {
+	d.OnBroadcastEvent(func(broadcastID string, ev Event) {
+		if m, ok := ev.(SuperHeartMessage); ok {
+			a.Add(broadcastID, m)
+		}
+	})
+}
+
+func (a *SuperHeartAggregator) Add(broadcastID string, m SuperHeartMessage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t, ok := a.broadcasts[broadcastID]
+	if !ok {
+		t = &superHeartTally{
+			tiers:      map[int32]int{},
+			supporters: map[string]*SupporterTotal{},
+		}
+		a.broadcasts[broadcastID] = t
+	}
+
+	t.amount += int64(m.Amount)
+	t.count++
+	t.tiers[m.Tier]++
+	addSupporter(t.supporters, m)
+	addSupporter(a.users, m)
+}
+
+func addSupporter(supporters map[string]*SupporterTotal, m SuperHeartMessage) {
+	key := m.User.ID
+	if key == "" {
+		key = m.User.Username
+	}
+
+	s, ok := supporters[key]
+	if !ok {
+		s = &SupporterTotal{}
+		supporters[key] = s
+	}
+	s.User = m.User
+	s.Amount += int64(m.Amount)
+	s.Count++
+}
+
+// Snapshot returns the totals of a broadcast with its supporters ordered by amount.
+func (a *SuperHeartAggregator) Snapshot(broadcastID string) *SuperHeartSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := &SuperHeartSnapshot{
+		BroadcastID: broadcastID,
+		Tiers:       map[int32]int{},
+	}
+	t, ok := a.broadcasts[broadcastID]
+	if !ok {
+		return s
+	}
+
+	s.Amount = t.amount
+	s.Count = t.count
+	for tier, n := range t.tiers {
+		s.Tiers[tier] = n
+	}
+	s.Supporters = sortedSupporters(t.supporters)
+	return s
+}
+
+// Finish returns the final snapshot of a broadcast and drops its per-broadcast state. User totals are kept.
+func (a *SuperHeartAggregator) Finish(broadcastID string) *SuperHeartSnapshot {
+	s := a.Snapshot(broadcastID)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.broadcasts, broadcastID)
+
+	return s
+}
+
+// TopSupporters returns up to n supporters of a broadcast ordered by amount.
+func (a *SuperHeartAggregator) TopSupporters(broadcastID string, n int) []SupporterTotal {
+	supporters := a.Snapshot(broadcastID).Supporters
+	if len(supporters) > n {
+		supporters = supporters[:n]
+	}
+	return supporters
+}
+
+// UserTotals returns the totals of every user across all broadcasts ordered by amount.
+func (a *SuperHeartAggregator) UserTotals() []SupporterTotal {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return sortedSupporters(a.users)
+}
+
+func sortedSupporters(supporters map[string]*SupporterTotal) []SupporterTotal {
+	result := make([]SupporterTotal, 0, len(supporters))
+	for _, s := range supporters {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Amount != result[j].Amount {
+			return result[i].Amount > result[j].Amount
+		}
+		return result[i].User.ID < result[j].User.ID
+	})
+	return result
+}
+
+func (s SuperHeartSnapshot) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// WriteCSV writes one row per supporter, ranked by amount.
+func (s SuperHeartSnapshot) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"rank", "user_id", "username", "display_name", "amount", "count"})
+	for i, supporter := range s.Supporters {
+		cw.Write([]string{
+			strconv.Itoa(i + 1),
+			supporter.User.ID,
+			supporter.User.Username,
+			supporter.User.DisplayName,
+			strconv.FormatInt(supporter.Amount, 10),
+			strconv.Itoa(supporter.Count),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,64 @@
+package goperiscope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuperHeartAggregator(t *testing.T) {
+
+	a := NewSuperHeartAggregator()
+	d := NewDispatcher(DispatcherConfig{BufferSize: 10})
+	a.Attach(d)
+
+	hoge := User{ID: "1111", Username: "hoge"}
+	fuga := User{ID: "2222", Username: "fuga", DisplayName: "Fuga, Inc."}
+
+	d.Run(context.Background(), newTestEventStream("broadcast_1",
+		SuperHeartMessage{Type: EventTypeSuperHeart, User: hoge, Amount: 100, Tier: 1},
+		SuperHeartMessage{Type: EventTypeSuperHeart, User: fuga, Amount: 500, Tier: 2},
+		ChatMessage{Type: EventTypeChat, User: hoge},
+		SuperHeartMessage{Type: EventTypeSuperHeart, User: hoge, Amount: 100, Tier: 1},
+	))
+	d.Run(context.Background(), newTestEventStream("broadcast_2",
+		SuperHeartMessage{Type: EventTypeSuperHeart, User: hoge, Amount: 1000, Tier: 3},
+	))
+	d.Close()
+
+	s := a.Snapshot("broadcast_1")
+	assert.Equal(t, int64(700), s.Amount)
+	assert.Equal(t, 3, s.Count)
+	assert.Equal(t, map[int32]int{1: 2, 2: 1}, s.Tiers)
+	assert.Equal(t, []SupporterTotal{
+		{User: fuga, Amount: 500, Count: 1},
+		{User: hoge, Amount: 200, Count: 2},
+	}, s.Supporters)
+
+	top := a.TopSupporters("broadcast_1", 1)
+	assert.Equal(t, []SupporterTotal{{User: fuga, Amount: 500, Count: 1}}, top)
+
+	assert.Equal(t, []SupporterTotal{
+		{User: hoge, Amount: 1200, Count: 3},
+		{User: fuga, Amount: 500, Count: 1},
+	}, a.UserTotals())
+
+	var csvBuf bytes.Buffer
+	assert.NoError(t, s.WriteCSV(&csvBuf))
+	assert.Equal(t, "rank,user_id,username,display_name,amount,count\n"+
+		"1,2222,fuga,\"Fuga, Inc.\",500,1\n"+
+		"2,1111,hoge,,200,2\n", csvBuf.String())
+
+	var jsonBuf bytes.Buffer
+	assert.NoError(t, s.WriteJSON(&jsonBuf))
+	var decoded SuperHeartSnapshot
+	assert.NoError(t, json.Unmarshal(jsonBuf.Bytes(), &decoded))
+	assert.Equal(t, *s, decoded)
+
+	final := a.Finish("broadcast_1")
+	assert.Equal(t, int64(700), final.Amount)
+	assert.Equal(t, int64(0), a.Snapshot("broadcast_1").Amount)
+}
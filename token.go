@@ -0,0 +1,110 @@
+package goperiscope
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tokenExpiryDelta is how long before the actual expiry an access token is refreshed.
+const tokenExpiryDelta = 60 * time.Second
+
+// TokenSource supplies access tokens to ClientImpl. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	// Token returns a valid access token, refreshing it if needed.
+	Token(ctx context.Context) (string, error)
+	// Invalidate discards accessToken if it is still the current one, so the next Token call refreshes.
+	Invalidate(accessToken string)
+}
+
+type refreshTokenSource struct {
+	authCli AuthClient
+	store   TokenStore
+	now     func() time.Time
+
+	mu          sync.Mutex
+	token       Token
+	invalidated string
+}
+
+func NewRefreshTokenSource(authCli AuthClient, refreshToken string) TokenSource {
+	return NewStoreTokenSource(authCli, NewMemoryTokenStore(refreshToken))
+}
+
+// NewStoreTokenSource returns a TokenSource that loads credentials from store and saves every refreshed token back to it.
+func NewStoreTokenSource(authCli AuthClient, store TokenStore) TokenSource {
+	return &refreshTokenSource{
+		authCli: authCli,
+		store:   store,
+		now:     time.Now,
+	}
+}
+
+func (s *refreshTokenSource) Token(ctx context.Context) (string, error) {
+	// holding the lock while refreshing lets concurrent callers share a single refresh.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.valid(s.token) {
+		return s.token.AccessToken, nil
+	}
+
+	// another process sharing the store may have refreshed already.
+	stored, err := s.store.Load()
+	if err != nil {
+		return "", errors.Wrapf(err, "TokenStore.Load is failed")
+	}
+	if stored.AccessToken != s.invalidated && s.valid(*stored) {
+		s.token = *stored
+		return s.token.AccessToken, nil
+	}
+
+	auth, err := s.authCli.OAuthRefreshContext(ctx, stored.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	token := Token{
+		AccessToken:  auth.AccessToken,
+		RefreshToken: stored.RefreshToken,
+	}
+	if auth.RefreshToken != "" {
+		token.RefreshToken = auth.RefreshToken
+	}
+	if auth.ExpiresIn > 0 {
+		token.Expiry = s.now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	}
+	if err := s.store.Save(&token); err != nil {
+		return "", errors.Wrapf(err, "TokenStore.Save is failed")
+	}
+
+	s.token = token
+	s.invalidated = ""
+	return s.token.AccessToken, nil
+}
+
+func (s *refreshTokenSource) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.AccessToken == accessToken {
+		s.token.AccessToken = ""
+		s.invalidated = accessToken
+	}
+}
+
+func (s *refreshTokenSource) valid(token Token) bool {
+	if token.AccessToken == "" {
+		return false
+	}
+	if token.Expiry.IsZero() {
+		return true
+	}
+	return s.now().Add(tokenExpiryDelta).Before(token.Expiry)
+}
+
+func isUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
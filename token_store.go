@@ -0,0 +1,110 @@
+package goperiscope
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// TokenStore persists credentials between refreshes. Load returns a zero Token when nothing is stored yet.
+type TokenStore interface {
+	Load() (*Token, error)
+	Save(token *Token) error
+}
+
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token Token
+}
+
+func NewMemoryTokenStore(refreshToken string) *MemoryTokenStore {
+	return &MemoryTokenStore{
+		token: Token{RefreshToken: refreshToken},
+	}
+}
+
+func (s *MemoryTokenStore) Load() (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := s.token
+	return &token, nil
+}
+
+func (s *MemoryTokenStore) Save(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = *token
+	return nil
+}
+
+// FileTokenStore keeps the token as JSON in a file readable only by its owner.
+// Writes go through a temporary file and a rename, so other processes never see a partial token.
+type FileTokenStore struct {
+	path string
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{
+		path: path,
+	}
+}
+
+func (s *FileTokenStore) Load() (*Token, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return &Token{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token Token
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, errors.Wrapf(err, "token file %s is broken", s.path)
+	}
+	return &token, nil
+}
+
+func (s *FileTokenStore) Save(token *Token) error {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,97 @@
+package goperiscope
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileTokenStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "goperiscope")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token.json")
+	s := NewFileTokenStore(path)
+
+	token, err := s.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, Token{}, *token)
+
+	expiry := time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)
+	err = s.Save(&Token{AccessToken: "hoge_access_token", RefreshToken: "hoge_refresh_token", Expiry: expiry})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	token, err = s.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "hoge_access_token", token.AccessToken)
+	assert.Equal(t, "hoge_refresh_token", token.RefreshToken)
+	assert.True(t, expiry.Equal(token.Expiry))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+type rotatingAuthClient struct {
+	stubAuthClient
+	refreshTokens []string
+}
+
+func (c *rotatingAuthClient) OAuthRefreshContext(ctx context.Context, refreshToken string) (*OAuthRefreshResponse, error) {
+	c.refreshTokens = append(c.refreshTokens, refreshToken)
+	auth, _ := c.stubAuthClient.OAuthRefreshContext(ctx, refreshToken)
+	auth.RefreshToken = "rotated_" + auth.AccessToken
+	return auth, nil
+}
+
+func TestStoreTokenSourceRotation(t *testing.T) {
+
+	authCli := &rotatingAuthClient{stubAuthClient: stubAuthClient{expiresIn: 3600}}
+	store := NewMemoryTokenStore("hoge_refresh_token")
+	s := NewStoreTokenSource(authCli, store)
+
+	token, err := s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_1", token)
+
+	stored, err := store.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, "token_1", stored.AccessToken)
+	assert.Equal(t, "rotated_token_1", stored.RefreshToken)
+
+	s.Invalidate("token_1")
+	token, err = s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_2", token)
+	assert.Equal(t, []string{"hoge_refresh_token", "rotated_token_1"}, authCli.refreshTokens)
+}
+
+func TestStoreTokenSourceSharedStore(t *testing.T) {
+
+	store := NewMemoryTokenStore("hoge_refresh_token")
+	store.Save(&Token{
+		AccessToken:  "stored_token",
+		RefreshToken: "hoge_refresh_token",
+		Expiry:       time.Now().Add(time.Hour),
+	})
+
+	authCli := &stubAuthClient{expiresIn: 3600}
+	s := NewStoreTokenSource(authCli, store)
+
+	token, err := s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "stored_token", token)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&authCli.calls))
+}
@@ -0,0 +1,116 @@
+package goperiscope
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stubAuthClient struct {
+	AuthClient
+	calls     int32
+	expiresIn int
+}
+
+func (c *stubAuthClient) OAuthRefresh(refreshToken string) (*OAuthRefreshResponse, error) {
+	return c.OAuthRefreshContext(context.Background(), refreshToken)
+}
+
+func (c *stubAuthClient) OAuthRefreshContext(ctx context.Context, refreshToken string) (*OAuthRefreshResponse, error) {
+	n := atomic.AddInt32(&c.calls, 1)
+	return &OAuthRefreshResponse{
+		AccessToken: fmt.Sprintf("token_%d", n),
+		ExpiresIn:   c.expiresIn,
+		TokenType:   "Bearer",
+	}, nil
+}
+
+func TestRefreshTokenSourceExpiry(t *testing.T) {
+
+	authCli := &stubAuthClient{expiresIn: 3600}
+	now := time.Now()
+
+	s := NewRefreshTokenSource(authCli, "hoge_refresh_token").(*refreshTokenSource)
+	s.now = func() time.Time { return now }
+
+	token, err := s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_1", token)
+
+	now = now.Add(30 * time.Minute)
+	token, err = s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_1", token)
+
+	// refreshed ahead of the actual expiry
+	now = now.Add(30*time.Minute - tokenExpiryDelta)
+	token, err = s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_2", token)
+
+	s.Invalidate("token_1")
+	token, err = s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_2", token)
+
+	s.Invalidate("token_2")
+	token, err = s.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token_3", token)
+}
+
+func TestRefreshTokenSourceConcurrent(t *testing.T) {
+
+	authCli := &stubAuthClient{expiresIn: 3600}
+	s := NewRefreshTokenSource(authCli, "hoge_refresh_token")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := s.Token(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, "token_1", token)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&authCli.calls))
+}
+
+func TestRetryOnUnauthorized(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer token_2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"token expired"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"region":"ap-northeast-1"}`))
+	}))
+	defer ts.Close()
+
+	authCli := &stubAuthClient{expiresIn: 3600}
+
+	c := ClientImpl{
+		urlBase:     ts.URL,
+		httpCli:     &http.Client{},
+		useragent:   "goperiscope test",
+		tokenSource: NewRefreshTokenSource(authCli, "hoge_refresh_token"),
+	}
+
+	result, err := c.GetRegion()
+	assert.NoError(t, err)
+	assert.Equal(t, "ap-northeast-1", result.Region)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&authCli.calls))
+}
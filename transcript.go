@@ -0,0 +1,277 @@
+package goperiscope
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TranscriptEntry is one line of a chat transcript file.
+type TranscriptEntry struct {
+	BroadcastID string          `json:"broadcast_id"`
+	Type        string          `json:"type"`
+	ServerTime  *time.Time      `json:"server_time,omitempty"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Event       json.RawMessage `json:"event"`
+}
+
+// Decode returns the recorded event as the message struct matching its type.
+func (e TranscriptEntry) Decode() (Event, error) {
+	// events built in code may leave their type field empty, so the recorded type comes first
+	if e.Type != "" {
+		return decodeEventAs(e.Type, e.Event)
+	}
+	return DecodeEvent(e.Event)
+}
+
+func eventServerTime(ev Event) *time.Time {
+	var ms int64
+	switch m := ev.(type) {
+	case ChatMessage:
+		ms = m.Timestamp
+	case HeartMessage:
+		ms = m.Timestamp
+	case JoinMessage:
+		ms = m.Timestamp
+	case ShareMessage:
+		ms = m.Timestamp
+	case SuperHeartMessage:
+		ms = m.Timestamp
+	}
+	if ms == 0 {
+		return nil
+	}
+	t := time.Unix(0, ms*int64(time.Millisecond))
+	return &t
+}
+
+func isTranscriptEvent(ev Event) bool {
+	switch ev.(type) {
+	case ChatMessage, HeartMessage, JoinMessage, ShareMessage, SuperHeartMessage:
+		return true
+	}
+	return false
+}
+
+// TranscriptRecorder appends chat events to a JSON Lines file per broadcast in a directory.
+type TranscriptRecorder struct {
+	dir string
+	now func() time.Time
+
+	mu    sync.Mutex
+	files map[string]*os.File
+	err   error
+}
+
+func NewTranscriptRecorder(dir string) *TranscriptRecorder {
+	return &TranscriptRecorder{
+		dir:   dir,
+		now:   time.Now,
+		files: map[string]*os.File{},
+	}
+}
+
+// Path returns the transcript file of a broadcast.
+func (r *TranscriptRecorder) Path(broadcastID string) string {
+	return filepath.Join(r.dir, filepath.Base(broadcastID)+".jsonl")
+}
+
+// Attach records the events of d. Write failures are kept and reported by Err.
+func (r *TranscriptRecorder) Attach(d *Dispatcher) {
+	d.OnBroadcastEvent(func(broadcastID string, ev Event) {
+		if err := r.Record(broadcastID, ev); err != nil {
+			r.mu.Lock()
+			if r.err == nil {
+				r.err = err
+			}
+			r.mu.Unlock()
+		}
+	})
+}
+
+// Err returns the first error hit by an attached recorder.
+func (r *TranscriptRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Record appends ev to the broadcast's transcript. Events other than chat, hearts, joins, shares and super hearts are ignored.
+func (r *TranscriptRecorder) Record(broadcastID string, ev Event) error {
+	if !isTranscriptEvent(ev) {
+		return nil
+	}
+
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrapf(err, "%s event could not be encoded", ev.EventType())
+	}
+	line, err := json.Marshal(TranscriptEntry{
+		BroadcastID: broadcastID,
+		Type:        ev.EventType(),
+		ServerTime:  eventServerTime(ev),
+		ReceivedAt:  r.now(),
+		Event:       raw,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "transcript entry could not be encoded")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.files[broadcastID]
+	if !ok {
+		path := r.Path(broadcastID)
+		if f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+			return errors.Wrapf(err, "transcript %s could not be opened", path)
+		}
+		r.files[broadcastID] = f
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return errors.Wrapf(err, "transcript %s could not be written", f.Name())
+	}
+	return nil
+}
+
+// CloseBroadcast closes the transcript file of a finished broadcast.
+func (r *TranscriptRecorder) CloseBroadcast(broadcastID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.files[broadcastID]
+	if !ok {
+		return nil
+	}
+	delete(r.files, broadcastID)
+	return f.Close()
+}
+
+func (r *TranscriptRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for id, f := range r.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.files, id)
+	}
+	return firstErr
+}
+
+func ReadTranscript(rd io.Reader) ([]TranscriptEntry, error) {
+	var entries []TranscriptEntry
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e TranscriptEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, errors.Wrapf(err, "invalid transcript line %d", n)
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "transcript could not be read")
+	}
+	return entries, nil
+}
+
+// TranscriptReplay re-emits recorded events as an EventStream,
+// keeping the original gaps between receive times divided by the speed factor.
+type TranscriptReplay struct {
+	broadcastID string
+	events      chan Event
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewTranscriptReplay starts replaying entries. A speed of 1 replays in real time, 10 ten times faster
+// and 0 or less emits every event without waiting. Entries that fail to decode are skipped.
+func NewTranscriptReplay(entries []TranscriptEntry, speed float64) *TranscriptReplay {
+	r := &TranscriptReplay{
+		events: make(chan Event),
+		done:   make(chan struct{}),
+	}
+	if len(entries) > 0 {
+		r.broadcastID = entries[0].BroadcastID
+	}
+
+	go r.run(entries, speed)
+	return r
+}
+
+// OpenTranscript replays a transcript file written by TranscriptRecorder.
+func OpenTranscript(path string, speed float64) (*TranscriptReplay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "transcript %s could not be opened", path)
+	}
+	defer f.Close()
+
+	entries, err := ReadTranscript(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "transcript %s is broken", path)
+	}
+	return NewTranscriptReplay(entries, speed), nil
+}
+
+func (r *TranscriptReplay) run(entries []TranscriptEntry, speed float64) {
+	defer close(r.events)
+
+	for i, e := range entries {
+		if i > 0 && speed > 0 {
+			gap := time.Duration(float64(e.ReceivedAt.Sub(entries[i-1].ReceivedAt)) / speed)
+			if gap > 0 {
+				timer := time.NewTimer(gap)
+				select {
+				case <-timer.C:
+				case <-r.done:
+					timer.Stop()
+					return
+				}
+			}
+		}
+
+		ev, err := e.Decode()
+		if err != nil {
+			continue
+		}
+		select {
+		case r.events <- ev:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *TranscriptReplay) BroadcastID() string {
+	return r.broadcastID
+}
+
+func (r *TranscriptReplay) Events() <-chan Event {
+	return r.events
+}
+
+// Err is always nil; entries that fail to decode are skipped.
+func (r *TranscriptReplay) Err() error {
+	return nil
+}
+
+func (r *TranscriptReplay) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+	return nil
+}
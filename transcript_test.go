@@ -0,0 +1,100 @@
+package goperiscope
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranscriptRecorder(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "transcript")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	start := time.Unix(1000, 0)
+	received := start
+	r := NewTranscriptRecorder(dir)
+	r.now = func() time.Time { return received }
+
+	assert.NoError(t, r.Record("b1", ChatMessage{Type: EventTypeChat, Text: "hi", Timestamp: 999500}))
+	received = start.Add(2 * time.Second)
+	assert.NoError(t, r.Record("b1", ViewerCountMessage{Type: EventTypeViewerCount, Live: 3}))
+	// the type field is left empty, as events built in code often do
+	assert.NoError(t, r.Record("b1", HeartMessage{Color: "#fff"}))
+	assert.NoError(t, r.Record("b2", JoinMessage{Type: EventTypeJoin}))
+	assert.NoError(t, r.Close())
+
+	f, err := os.Open(r.Path("b1"))
+	assert.NoError(t, err)
+	defer f.Close()
+	entries, err := ReadTranscript(f)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Equal(t, "b1", entries[0].BroadcastID)
+	assert.Equal(t, EventTypeChat, entries[0].Type)
+	assert.True(t, start.Equal(entries[0].ReceivedAt))
+	if assert.NotNil(t, entries[0].ServerTime) {
+		assert.True(t, time.Unix(999, 500000000).Equal(*entries[0].ServerTime))
+	}
+	ev, err := entries[0].Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", ev.(ChatMessage).Text)
+
+	assert.Equal(t, EventTypeHeart, entries[1].Type)
+	assert.Nil(t, entries[1].ServerTime)
+	ev, err = entries[1].Decode()
+	assert.NoError(t, err)
+	if assert.IsType(t, HeartMessage{}, ev) {
+		assert.Equal(t, "#fff", ev.(HeartMessage).Color)
+	}
+
+	_, err = os.Stat(r.Path("b2"))
+	assert.NoError(t, err)
+}
+
+func TestTranscriptReplay(t *testing.T) {
+
+	start := time.Unix(1000, 0)
+	entries := []TranscriptEntry{
+		{BroadcastID: "b1", Type: EventTypeChat, ReceivedAt: start, Event: []byte(`{"type":"chat","text":"one"}`)},
+		{BroadcastID: "b1", Type: EventTypeHeart, ReceivedAt: start.Add(time.Second), Event: []byte(`{"type":"heart"}`)},
+		{BroadcastID: "b1", Type: EventTypeChat, ReceivedAt: start.Add(2 * time.Second), Event: []byte(`{"type":"chat","text":"two"}`)},
+	}
+
+	began := time.Now()
+	replay := NewTranscriptReplay(entries, 20)
+	assert.Equal(t, "b1", replay.BroadcastID())
+
+	var got []Event
+	for ev := range replay.Events() {
+		got = append(got, ev)
+	}
+	elapsed := time.Since(began)
+
+	assert.Len(t, got, 3)
+	assert.Equal(t, "one", got[0].(ChatMessage).Text)
+	assert.Equal(t, EventTypeHeart, got[1].EventType())
+	assert.Equal(t, "two", got[2].(ChatMessage).Text)
+	assert.True(t, elapsed >= 100*time.Millisecond, "replay finished in %s", elapsed)
+	assert.True(t, elapsed < time.Second, "replay finished in %s", elapsed)
+	assert.NoError(t, replay.Close())
+}
+
+func TestTranscriptReplayClose(t *testing.T) {
+
+	start := time.Unix(1000, 0)
+	replay := NewTranscriptReplay([]TranscriptEntry{
+		{BroadcastID: "b1", ReceivedAt: start, Event: []byte(`{"type":"chat"}`)},
+		{BroadcastID: "b1", ReceivedAt: start.Add(time.Hour), Event: []byte(`{"type":"chat"}`)},
+	}, 1)
+
+	<-replay.Events()
+	assert.NoError(t, replay.Close())
+	_, ok := <-replay.Events()
+	assert.False(t, ok)
+}
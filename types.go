@@ -1,15 +1,23 @@
 package goperiscope
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 type Broadcast struct {
-	ID    string `json:"id"`
-	State string `json:"state"`
-	Title string `json:"title"`
+	ID             string         `json:"id"`
+	State          BroadcastState `json:"state"`
+	Title          string         `json:"title"`
+	IsStreamActive bool           `json:"is_stream_active"`
 }
 
 func (b Broadcast) String() string {
-	return fmt.Sprintf("id=%s,state=%s,title=%s", b.ID, b.State, b.Title)
+	return fmt.Sprintf("id=%s,state=%s,title=%s,is_stream_active=%t", b.ID, b.State, b.Title, b.IsStreamActive)
 }
 
 type Encoder struct {
@@ -40,7 +48,7 @@ type StreamConfiguration struct {
 }
 
 func (c StreamConfiguration) String() string {
-	return fmt.Sprintf("video_codec=%s,video_bitrate=%d,framerate=%d,keyframe_interval=%d,width=%d,height=%d,audio_codec=%s,audio_sampling_rate=%d,audio_bitrate=%d,audio_num_channels=%s",
+	return fmt.Sprintf("video_codec=%s,video_bitrate=%d,framerate=%d,keyframe_interval=%d,width=%d,height=%d,audio_codec=%s,audio_sampling_rate=%d,audio_bitrate=%d,audio_num_channels=%d",
 		c.VideoCodec, c.VideoBitrate, c.Framerate, c.KeyframeInterval, c.Width, c.Height, c.AudioCodec, c.AudioSamplingRate, c.AudioBitrate, c.AudioNumChannels)
 }
 
@@ -86,20 +94,24 @@ type ChatMessage struct {
 	Text  string `json:"text"`
 	User  User   `json:"user"`
 	Color string `json:"color"`
+	// Timestamp is the server send time in milliseconds since the Unix epoch, when provided.
+	Timestamp int64 `json:"timestamp,omitempty"`
 }
 
 type HeartMessage struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	User  User   `json:"user"`
-	Color string `json:"color"`
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	User      User   `json:"user"`
+	Color     string `json:"color"`
+	Timestamp int64  `json:"timestamp,omitempty"`
 }
 
 type JoinMessage struct {
-	ID    string `json:"id"`
-	Type  string `json:"type"`
-	User  User   `json:"user"`
-	Color string `json:"color"`
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	User      User   `json:"user"`
+	Color     string `json:"color"`
+	Timestamp int64  `json:"timestamp,omitempty"`
 }
 
 type ScreenshotMessage struct {
@@ -110,19 +122,21 @@ type ScreenshotMessage struct {
 }
 
 type ShareMessage struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Service string `json:"service"`
-	User    User   `json:"user"`
-	Color   string `json:"color"`
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Service   string `json:"service"`
+	User      User   `json:"user"`
+	Color     string `json:"color"`
+	Timestamp int64  `json:"timestamp,omitempty"`
 }
 
 type SuperHeartMessage struct {
-	Type   string `json:"type"`
-	User   User   `json:"user"`
-	Color  string `json:"color"`
-	Amount int32  `json:"amount"`
-	Tier   int32  `json:"tier"`
+	Type      string `json:"type"`
+	User      User   `json:"user"`
+	Color     string `json:"color"`
+	Amount    int32  `json:"amount"`
+	Tier      int32  `json:"tier"`
+	Timestamp int64  `json:"timestamp,omitempty"`
 }
 
 type ViewerCountMessage struct {
@@ -147,29 +161,89 @@ func (i internalError) String() string {
 	return fmt.Sprintf("message=%v, documentationURL=%v", i.Message, i.DocumentationURL)
 }
 
+var (
+	ErrUnauthorized            = errors.New("unauthorized")
+	ErrForbidden               = errors.New("forbidden")
+	ErrNotFound                = errors.New("not found")
+	ErrRateLimited             = errors.New("rate limited")
+	ErrBroadcastAlreadyStopped = errors.New("broadcast is already stopped")
+	ErrValidation              = errors.New("validation failed")
+	ErrServer                  = errors.New("server error")
+)
+
+// Error is returned for every non-2xx API response.
+// It matches one of the sentinel errors above with errors.Is.
 type Error struct {
-	StatusCode    int
-	Params        fmt.Stringer
-	InternalError fmt.Stringer
+	StatusCode       int
+	Message          string
+	DocumentationURL string
+	RequestID        string
+	Header           http.Header
+	Body             string // leading part of the raw response body
+	Params           fmt.Stringer
+	InternalError    fmt.Stringer
+
+	retryAfter time.Duration
 }
 
 func (e Error) Error() string {
-	return fmt.Sprintf(
-		`statusCode="%d" params="%s" error="%v"]`,
-		e.StatusCode,
-		e.Params,
-		e.InternalError,
-	)
+	s := fmt.Sprintf(`statusCode="%d"`, e.StatusCode)
+	if e.Params != nil {
+		s += fmt.Sprintf(` params="%s"`, e.Params)
+	}
+	s += fmt.Sprintf(` error="%v"`, e.InternalError)
+	if e.Message == "" && e.Body != "" {
+		s += fmt.Sprintf(` body="%s"`, e.Body)
+	}
+	if e.RequestID != "" {
+		s += fmt.Sprintf(` requestID="%s"`, e.RequestID)
+	}
+	return s + "]"
 }
 
 func (e Error) HTTPStatusCode() int {
 	return e.StatusCode
 }
 
+func (e Error) Is(target error) bool {
+	return target != nil && target == e.kind()
+}
+
+func (e Error) kind() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case (e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusConflict) && isAlreadyStoppedMessage(e.Message):
+		return ErrBroadcastAlreadyStopped
+	case e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrValidation
+	case e.StatusCode >= 500:
+		return ErrServer
+	}
+	return nil
+}
+
+func isAlreadyStoppedMessage(message string) bool {
+	message = strings.ToLower(message)
+	return strings.Contains(message, "already") &&
+		(strings.Contains(message, "stopped") || strings.Contains(message, "ended"))
+}
+
 func NewError(statusCode int, params, internalErr fmt.Stringer) error {
-	return &Error{
+	e := &Error{
 		StatusCode:    statusCode,
 		Params:        params,
 		InternalError: internalErr,
 	}
+	if i, ok := internalErr.(internalError); ok {
+		e.Message = i.Message
+		e.DocumentationURL = i.DocumentationURL
+	}
+	return e
 }
@@ -0,0 +1,197 @@
+package goperiscope
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultViewerCountCapacity = 4096
+
+type ViewerCountSample struct {
+	Time  time.Time `json:"time"`
+	Live  int32     `json:"live"`
+	Total int32     `json:"total"`
+}
+
+type RetentionPoint struct {
+	Offset time.Duration `json:"offset"`
+	// Ratio is the live viewer count relative to the peak.
+	Ratio float64 `json:"ratio"`
+}
+
+type ViewerCountReport struct {
+	BroadcastID string           `json:"broadcast_id"`
+	Start       time.Time        `json:"start"`
+	End         time.Time        `json:"end"`
+	PeakLive    int32            `json:"peak_live"`
+	PeakAt      time.Time        `json:"peak_at"`
+	TimeToPeak  time.Duration    `json:"time_to_peak"`
+	AverageLive float64          `json:"average_live"`
+	Total       int32            `json:"total"`
+	Retention   []RetentionPoint `json:"retention"`
+}
+
+// ViewerCountRecorder keeps the latest viewer count samples of each broadcast in a ring buffer.
+type ViewerCountRecorder struct {
+	capacity int
+	now      func() time.Time
+
+	mu         sync.Mutex
+	broadcasts map[string]*viewerCountRing
+}
+
+type viewerCountRing struct {
+	samples []ViewerCountSample
+	head    int
+	full    bool
+}
+
+func (r *viewerCountRing) add(s ViewerCountSample) {
+	r.samples[r.head] = s
+	r.head = (r.head + 1) % len(r.samples)
+	if r.head == 0 {
+		r.full = true
+	}
+}
+
+func (r *viewerCountRing) list() []ViewerCountSample {
+	if !r.full {
+		return append([]ViewerCountSample(nil), r.samples[:r.head]...)
+	}
+	return append(append([]ViewerCountSample(nil), r.samples[r.head:]...), r.samples[:r.head]...)
+}
+
+// NewViewerCountRecorder keeps up to capacity samples per broadcast, dropping the oldest ones.
+func NewViewerCountRecorder(capacity int) *ViewerCountRecorder {
+	if capacity <= 0 {
+		capacity = defaultViewerCountCapacity
+	}
+	return &ViewerCountRecorder{
+		capacity:   capacity,
+		now:        time.Now,
+		broadcasts: map[string]*viewerCountRing{},
+	}
+}
+
+// Attach records the viewer counts of every broadcast dispatched by d.
+func (r *ViewerCountRecorder) Attach(d *Dispatcher) {
+	d.OnBroadcastEvent(func(broadcastID string, ev Event) {
+		if m, ok := ev.(ViewerCountMessage); ok {
+			r.Add(broadcastID, m)
+		}
+	})
+}
+
+func (r *ViewerCountRecorder) Add(broadcastID string, m ViewerCountMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ring, ok := r.broadcasts[broadcastID]
+	if !ok {
+		ring = &viewerCountRing{samples: make([]ViewerCountSample, r.capacity)}
+		r.broadcasts[broadcastID] = ring
+	}
+	ring.add(ViewerCountSample{Time: r.now(), Live: m.Live, Total: m.Total})
+}
+
+func (r *ViewerCountRecorder) Samples(broadcastID string) []ViewerCountSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ring, ok := r.broadcasts[broadcastID]
+	if !ok {
+		return nil
+	}
+	return ring.list()
+}
+
+// Report summarizes the recorded samples of a broadcast.
+func (r *ViewerCountRecorder) Report(broadcastID string) *ViewerCountReport {
+	return newViewerCountReport(broadcastID, r.Samples(broadcastID))
+}
+
+// Finish returns the report of a broadcast and drops its samples.
+func (r *ViewerCountRecorder) Finish(broadcastID string) *ViewerCountReport {
+	report := r.Report(broadcastID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.broadcasts, broadcastID)
+
+	return report
+}
+
+func newViewerCountReport(broadcastID string, samples []ViewerCountSample) *ViewerCountReport {
+	report := &ViewerCountReport{
+		BroadcastID: broadcastID,
+	}
+	if len(samples) == 0 {
+		return report
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	report.Start = first.Time
+	report.End = last.Time
+
+	// the average is weighted by how long each count was current
+	var weighted float64
+	for i, s := range samples {
+		if s.Live > report.PeakLive || i == 0 {
+			report.PeakLive = s.Live
+			report.PeakAt = s.Time
+		}
+		if s.Total > report.Total {
+			report.Total = s.Total
+		}
+		if i+1 < len(samples) {
+			weighted += float64(s.Live) * samples[i+1].Time.Sub(s.Time).Seconds()
+		}
+	}
+	report.TimeToPeak = report.PeakAt.Sub(first.Time)
+
+	if d := last.Time.Sub(first.Time).Seconds(); d > 0 {
+		report.AverageLive = weighted / d
+	} else {
+		report.AverageLive = float64(last.Live)
+	}
+
+	if report.PeakLive > 0 {
+		for _, s := range samples {
+			report.Retention = append(report.Retention, RetentionPoint{
+				Offset: s.Time.Sub(first.Time),
+				Ratio:  float64(s.Live) / float64(report.PeakLive),
+			})
+		}
+	}
+
+	return report
+}
+
+// WrapClient returns a Client that calls onReport with the viewer count report of a broadcast
+// once StopBroadcast succeeds for it.
+func (r *ViewerCountRecorder) WrapClient(c Client, onReport func(*ViewerCountReport)) Client {
+	return &viewerCountReportingClient{
+		Client:   c,
+		recorder: r,
+		onReport: onReport,
+	}
+}
+
+type viewerCountReportingClient struct {
+	Client
+	recorder *ViewerCountRecorder
+	onReport func(*ViewerCountReport)
+}
+
+func (c *viewerCountReportingClient) StopBroadcast(broadcastID string) error {
+	return c.StopBroadcastContext(context.Background(), broadcastID)
+}
+
+func (c *viewerCountReportingClient) StopBroadcastContext(ctx context.Context, broadcastID string) error {
+	if err := c.Client.StopBroadcastContext(ctx, broadcastID); err != nil {
+		return err
+	}
+	c.onReport(c.recorder.Finish(broadcastID))
+	return nil
+}
@@ -0,0 +1,60 @@
+package goperiscope
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stopOnlyClient struct {
+	Client
+	stopped []string
+}
+
+func (c *stopOnlyClient) StopBroadcastContext(ctx context.Context, broadcastID string) error {
+	c.stopped = append(c.stopped, broadcastID)
+	return nil
+}
+
+func TestViewerCountRecorder(t *testing.T) {
+
+	start := time.Date(2018, 4, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+
+	r := NewViewerCountRecorder(3)
+	r.now = func() time.Time { return now }
+
+	for _, live := range []int32{5, 10, 30, 20} {
+		r.Add("broadcast_id", ViewerCountMessage{Live: live, Total: live * 2})
+		now = now.Add(10 * time.Second)
+	}
+
+	// the oldest sample is dropped
+	samples := r.Samples("broadcast_id")
+	assert.Len(t, samples, 3)
+	assert.Equal(t, int32(10), samples[0].Live)
+
+	report := r.Report("broadcast_id")
+	assert.Equal(t, start.Add(10*time.Second), report.Start)
+	assert.Equal(t, start.Add(30*time.Second), report.End)
+	assert.Equal(t, int32(30), report.PeakLive)
+	assert.Equal(t, 10*time.Second, report.TimeToPeak)
+	assert.Equal(t, int32(60), report.Total)
+	assert.InDelta(t, 20.0, report.AverageLive, 0.001)
+	assert.Equal(t, []RetentionPoint{
+		{Offset: 0, Ratio: 10.0 / 30.0},
+		{Offset: 10 * time.Second, Ratio: 1},
+		{Offset: 20 * time.Second, Ratio: 20.0 / 30.0},
+	}, report.Retention)
+
+	var reported *ViewerCountReport
+	c := &stopOnlyClient{}
+	wrapped := r.WrapClient(c, func(report *ViewerCountReport) { reported = report })
+
+	assert.NoError(t, wrapped.StopBroadcast("broadcast_id"))
+	assert.Equal(t, []string{"broadcast_id"}, c.stopped)
+	assert.Equal(t, report, reported)
+	assert.Nil(t, r.Samples("broadcast_id"))
+}